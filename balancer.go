@@ -0,0 +1,297 @@
+package request
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+var (
+	// ErrNoHosts is returned when a load balanced request has no hosts left to try.
+	ErrNoHosts = exception.New("request: no hosts available")
+)
+
+// LoadBalanceStrategy selects how a load balancer picks a host.
+type LoadBalanceStrategy string
+
+const (
+	// LoadBalanceRoundRobin picks hosts in turn.
+	LoadBalanceRoundRobin LoadBalanceStrategy = "round-robin"
+	// LoadBalanceRandom picks a host at random.
+	LoadBalanceRandom LoadBalanceStrategy = "random"
+	// LoadBalanceLeastOutstanding picks the host with the fewest requests in flight.
+	LoadBalanceLeastOutstanding LoadBalanceStrategy = "least-outstanding"
+	// LoadBalanceWeighted picks hosts in turn in proportion to their weights, see `AddHost`.
+	LoadBalanceWeighted LoadBalanceStrategy = "weighted"
+)
+
+const (
+	// DefaultLoadBalancerEjectAfter is the default number of consecutive failures that eject a host.
+	DefaultLoadBalancerEjectAfter = 5
+	// DefaultLoadBalancerEjectFor is the default time an ejected host is skipped.
+	DefaultLoadBalancerEjectFor = 30 * time.Second
+)
+
+// NewLoadBalancer returns a new round robin load balancer over the given hosts.
+func NewLoadBalancer(hosts ...string) *LoadBalancer {
+	lb := &LoadBalancer{
+		strategy:   LoadBalanceRoundRobin,
+		ejectAfter: DefaultLoadBalancerEjectAfter,
+		ejectFor:   DefaultLoadBalancerEjectFor,
+		random:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:        time.Now,
+	}
+	for _, host := range hosts {
+		lb.AddHost(host, 1)
+	}
+	return lb
+}
+
+// LoadBalancer spreads requests over several hosts.
+// Hosts that fail `ejectAfter` times in a row are skipped for `ejectFor`, unless every host has been ejected,
+// and requests that fail to connect are retried on another host.
+// A load balancer is safe to share between requests and goroutines.
+type LoadBalancer struct {
+	sync.Mutex
+
+	hosts      []*balancedHost
+	strategy   LoadBalanceStrategy
+	ejectAfter int
+	ejectFor   time.Duration
+	next       int
+	random     *rand.Rand
+	now        func() time.Time
+}
+
+type balancedHost struct {
+	host                string
+	weight              int
+	currentWeight       int
+	outstanding         int
+	consecutiveFailures int
+	ejectedUntil        time.Time
+}
+
+// AddHost adds a host with a weight, used by `LoadBalanceWeighted`.
+func (lb *LoadBalancer) AddHost(host string, weight int) *LoadBalancer {
+	lb.Lock()
+	defer lb.Unlock()
+	if weight < 1 {
+		weight = 1
+	}
+	lb.hosts = append(lb.hosts, &balancedHost{host: host, weight: weight})
+	return lb
+}
+
+// WithStrategy sets how hosts are picked.
+func (lb *LoadBalancer) WithStrategy(strategy LoadBalanceStrategy) *LoadBalancer {
+	lb.strategy = strategy
+	return lb
+}
+
+// WithEjection sets the number of consecutive failures that eject a host and for how long.
+// A zero `after` disables ejection.
+func (lb *LoadBalancer) WithEjection(after int, ejectFor time.Duration) *LoadBalancer {
+	lb.ejectAfter = after
+	lb.ejectFor = ejectFor
+	return lb
+}
+
+// Hosts returns the hosts of the load balancer.
+func (lb *LoadBalancer) Hosts() []string {
+	lb.Lock()
+	defer lb.Unlock()
+	hosts := make([]string, len(lb.hosts))
+	for index, host := range lb.hosts {
+		hosts[index] = host.host
+	}
+	return hosts
+}
+
+// Healthy returns if a host is not currently ejected.
+func (lb *LoadBalancer) Healthy(host string) bool {
+	lb.Lock()
+	defer lb.Unlock()
+	for _, bh := range lb.hosts {
+		if bh.host == host {
+			return !lb.now().Before(bh.ejectedUntil)
+		}
+	}
+	return false
+}
+
+// pick chooses a host that has not been tried yet and marks it as outstanding.
+func (lb *LoadBalancer) pick(tried map[string]bool) (string, error) {
+	lb.Lock()
+	defer lb.Unlock()
+
+	now := lb.now()
+	var healthy, ejected []*balancedHost
+	for _, bh := range lb.hosts {
+		if tried[bh.host] {
+			continue
+		}
+		if now.Before(bh.ejectedUntil) {
+			ejected = append(ejected, bh)
+		} else {
+			healthy = append(healthy, bh)
+		}
+	}
+	candidates := healthy
+	if len(candidates) == 0 {
+		// every remaining host is ejected; trying one beats failing outright.
+		candidates = ejected
+	}
+	if len(candidates) == 0 {
+		return "", ErrNoHosts
+	}
+
+	var chosen *balancedHost
+	switch lb.strategy {
+	case LoadBalanceRandom:
+		chosen = candidates[lb.random.Intn(len(candidates))]
+	case LoadBalanceLeastOutstanding:
+		for _, bh := range candidates {
+			if chosen == nil || bh.outstanding < chosen.outstanding {
+				chosen = bh
+			}
+		}
+	case LoadBalanceWeighted:
+		// smooth weighted round robin, as in nginx.
+		total := 0
+		for _, bh := range candidates {
+			bh.currentWeight += bh.weight
+			total += bh.weight
+			if chosen == nil || bh.currentWeight > chosen.currentWeight {
+				chosen = bh
+			}
+		}
+		chosen.currentWeight -= total
+	default:
+		chosen = candidates[lb.next%len(candidates)]
+		lb.next++
+	}
+
+	chosen.outstanding++
+	return chosen.host, nil
+}
+
+// done marks a request to a host as finished.
+func (lb *LoadBalancer) done(host string, failed bool) {
+	lb.Lock()
+	defer lb.Unlock()
+	for _, bh := range lb.hosts {
+		if bh.host != host {
+			continue
+		}
+		bh.outstanding--
+		if !failed {
+			bh.consecutiveFailures = 0
+			return
+		}
+		bh.consecutiveFailures++
+		if lb.ejectAfter > 0 && bh.consecutiveFailures >= lb.ejectAfter {
+			bh.consecutiveFailures = 0
+			bh.ejectedUntil = lb.now().Add(lb.ejectFor)
+		}
+		return
+	}
+}
+
+// WithLoadBalancer spreads the request over the hosts of a load balancer, replacing `Host`.
+// The chosen host is reflected in `URL()` and so in the request `Meta` while the request is being sent.
+func (hr *Request) WithLoadBalancer(lb *LoadBalancer) *Request {
+	hr.loadBalancer = lb
+	return hr
+}
+
+// WithHosts spreads the request over several hosts, failing over between them on connection errors.
+// Remarks: each call creates a new load balancer, so host health is only kept between calls of this request;
+// use `WithLoadBalancer` with a shared load balancer to keep host health and strategy state between requests.
+func (hr *Request) WithHosts(hosts ...string) *Request {
+	return hr.WithLoadBalancer(NewLoadBalancer(hosts...))
+}
+
+// balancedResponse makes the request against the hosts of the load balancer,
+// moving on to the next host when a connection cannot be made.
+func (hr *Request) balancedResponse() (*http.Response, error) {
+	tried := map[string]bool{}
+	for {
+		host, err := hr.loadBalancer.pick(tried)
+		if err != nil {
+			hr.logError(err, ErrorClassUnknown)
+			return nil, err
+		}
+		tried[host] = true
+		hr.resolvedHost = host
+
+		res, err := hr.roundTrip()
+		if err != nil {
+			// only errors sending the request say anything about the health of the host;
+			// requests that could not be built, or that the caller canceled or ran out of time for, do not.
+			failed := isTransportError(err) && hr.Context().Err() == nil
+			hr.loadBalancer.done(host, failed)
+			if failed && isConnectionError(err) && len(tried) < len(hr.loadBalancer.Hosts()) {
+				continue
+			}
+			return res, err
+		}
+
+		// a hedged request can be answered by another host, which reports its own outcome.
+		failed := res.StatusCode >= http.StatusInternalServerError && hr.resolvedHost == host
+		res.Body = &closeNotifier{ReadCloser: res.Body, onClose: func() {
+			hr.loadBalancer.done(host, failed)
+		}}
+		return res, nil
+	}
+}
+
+// isTransportError returns if an unwrapped round trip error was returned by the http client because the
+// request could not be sent or its response read, i.e. a dial, read, tls or timeout error.
+// The client wraps errors from `CheckRedirect` the same way, but those are not transport errors.
+func isTransportError(err error) bool {
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return true
+	}
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return false
+	}
+	cause := urlErr.Err
+	var netErr net.Error
+	return errors.As(cause, &netErr) ||
+		isTLSError(cause) ||
+		errors.Is(cause, io.EOF) ||
+		errors.Is(cause, io.ErrUnexpectedEOF)
+}
+
+// isConnectionError returns if an unwrapped round trip error happened before the request reached the host.
+func isConnectionError(err error) bool {
+	switch ClassifyError(err) {
+	case ErrorClassConnectionRefused, ErrorClassDNS:
+		return true
+	}
+	var timeoutErr *TimeoutError
+	return errors.As(err, &timeoutErr) && timeoutErr.Phase == TimeoutPhaseConnect
+}
+
+// closeNotifier calls a function once when the body is closed.
+type closeNotifier struct {
+	io.ReadCloser
+	once    sync.Once
+	onClose func()
+}
+
+func (cn *closeNotifier) Close() error {
+	err := cn.ReadCloser.Close()
+	cn.once.Do(cn.onClose)
+	return err
+}
@@ -0,0 +1,183 @@
+package request
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func hostOf(rawURL string) string {
+	parsed, _ := url.Parse(rawURL)
+	return parsed.Host
+}
+
+func TestLoadBalancerRoundRobin(t *testing.T) {
+	assert := assert.New(t)
+
+	lb := NewLoadBalancer("a", "b", "c")
+	var picked []string
+	for i := 0; i < 4; i++ {
+		host, err := lb.pick(nil)
+		assert.Nil(err)
+		lb.done(host, false)
+		picked = append(picked, host)
+	}
+	assert.Equal([]string{"a", "b", "c", "a"}, picked)
+}
+
+func TestLoadBalancerWeighted(t *testing.T) {
+	assert := assert.New(t)
+
+	lb := NewLoadBalancer().WithStrategy(LoadBalanceWeighted).AddHost("a", 3).AddHost("b", 1)
+	counts := map[string]int{}
+	for i := 0; i < 8; i++ {
+		host, err := lb.pick(nil)
+		assert.Nil(err)
+		lb.done(host, false)
+		counts[host]++
+	}
+	assert.Equal(map[string]int{"a": 6, "b": 2}, counts)
+}
+
+func TestLoadBalancerLeastOutstanding(t *testing.T) {
+	assert := assert.New(t)
+
+	lb := NewLoadBalancer("a", "b").WithStrategy(LoadBalanceLeastOutstanding)
+	first, _ := lb.pick(nil)
+	second, _ := lb.pick(nil)
+	assert.NotEqual(first, second)
+	lb.done(second, false)
+	third, _ := lb.pick(nil)
+	assert.Equal(second, third)
+}
+
+func TestLoadBalancerEjection(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2017, 01, 01, 12, 0, 0, 0, time.UTC)
+	lb := NewLoadBalancer("a", "b").WithEjection(2, time.Minute)
+	lb.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		host, _ := lb.pick(map[string]bool{"b": true})
+		lb.done(host, true)
+	}
+	assert.False(lb.Healthy("a"))
+	for i := 0; i < 3; i++ {
+		host, _ := lb.pick(nil)
+		assert.Equal("b", host)
+		lb.done(host, false)
+	}
+
+	// with every host ejected or tried, ejected hosts are still used.
+	host, err := lb.pick(map[string]bool{"b": true})
+	assert.Nil(err)
+	assert.Equal("a", host)
+
+	_, err = lb.pick(map[string]bool{"a": true, "b": true})
+	assert.Equal(ErrNoHosts, err)
+
+	now = now.Add(time.Minute)
+	assert.True(lb.Healthy("a"))
+}
+
+func TestWithHostsFailover(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer ts.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	deadHost := listener.Addr().String()
+	listener.Close()
+
+	var hosts []string
+	var respondedHost string
+	var status statusObject
+	err = New().WithURL("http://service/api").
+		WithHosts(deadHost, hostOf(ts.URL)).
+		OnRequest(func(meta *Meta) {
+			hosts = append(hosts, meta.URL.Host)
+		}).
+		OnResponse(func(meta *Meta, _ *ResponseMeta, _ []byte) {
+			respondedHost = meta.URL.Host
+		}).
+		JSON(&status)
+	assert.Nil(err)
+	assert.Equal("ok!", status.Status)
+	assert.Equal([]string{deadHost, hostOf(ts.URL)}, hosts)
+	assert.Equal(hostOf(ts.URL), respondedHost)
+}
+
+func TestLoadBalancerIgnoresRequestErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	lb := NewLoadBalancer("a:80", "b:80").WithEjection(1, time.Minute)
+	attempts := 0
+	err := New().WithURL("http://service/").
+		WithPathTemplate("/users/{id}").
+		WithPathParam("id", "..").
+		WithLoadBalancer(lb).
+		OnRequest(func(*Meta) { attempts++ }).
+		Execute()
+	assert.NotNil(err)
+	assert.Equal(0, attempts)
+	assert.True(lb.Healthy("a:80"))
+	assert.True(lb.Healthy("b:80"))
+}
+
+func TestWithLoadBalancerSharedHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, errorMeta(), statusOkObject())
+	})
+	defer ts.Close()
+
+	lb := NewLoadBalancer(hostOf(ts.URL), "other:80").WithEjection(1, time.Minute)
+	err := New().WithURL("http://service/").WithLoadBalancer(lb).Execute()
+	assert.Nil(err)
+	assert.False(lb.Healthy(hostOf(ts.URL)))
+	assert.True(lb.Healthy("other:80"))
+}
+
+func TestLoadBalancerIgnoresCallerCancellation(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	defer ts.Close()
+
+	lb := NewLoadBalancer(hostOf(ts.URL)).WithEjection(1, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := New().WithURL(ts.URL).WithLoadBalancer(lb).WithContext(ctx).Response()
+	assert.NotNil(err)
+	assert.True(lb.Healthy(hostOf(ts.URL)))
+}
+
+func TestLoadBalancerIgnoresRedirectPolicyErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(redirectingEndpoint("/c"))
+	defer ts.Close()
+
+	lb := NewLoadBalancer(hostOf(ts.URL)).WithEjection(1, time.Minute)
+	err := New().WithURL("http://service/a").
+		WithLoadBalancer(lb).
+		WithRedirectPolicy(RedirectPolicy{MaxRedirects: 1}).
+		Execute()
+	assert.NotNil(err)
+	assert.True(lb.Healthy(hostOf(ts.URL)))
+}
@@ -0,0 +1,162 @@
+package request
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	// DefaultBatchConcurrency is the number of requests a batch runs at once by default.
+	DefaultBatchConcurrency = 8
+)
+
+// NewBatch returns a new batch executor.
+func NewBatch() *Batch {
+	return &Batch{
+		concurrency: DefaultBatchConcurrency,
+	}
+}
+
+// Batch runs many requests with bounded concurrency.
+type Batch struct {
+	items       []batchItem
+	concurrency int
+	failFast    bool
+}
+
+type batchItem struct {
+	request     *Request
+	deserialize Deserializer
+}
+
+// BatchResult is the outcome of a single request in a batch.
+type BatchResult struct {
+	Request *Request
+	Meta    *ResponseMeta
+	Err     error
+}
+
+// Add adds a request to the batch with an optional deserializer for the response body.
+// If the deserializer is nil the response is read and discarded.
+func (b *Batch) Add(req *Request, deserialize Deserializer) *Batch {
+	b.items = append(b.items, batchItem{request: req, deserialize: deserialize})
+	return b
+}
+
+// AddJSON adds a request to the batch that unmarshals the response as json to the destination.
+func (b *Batch) AddJSON(req *Request, destination interface{}) *Batch {
+	return b.Add(req, newJSONDeserializer(destination))
+}
+
+// AddXML adds a request to the batch that unmarshals the response as xml to the destination.
+func (b *Batch) AddXML(req *Request, destination interface{}) *Batch {
+	return b.Add(req, newXMLDeserializer(destination))
+}
+
+// WithConcurrency sets the number of requests run at once.
+func (b *Batch) WithConcurrency(concurrency int) *Batch {
+	b.concurrency = concurrency
+	return b
+}
+
+// WithFailFast cancels the rest of the batch after the first error.
+// By default every request is run and all errors are collected.
+func (b *Batch) WithFailFast() *Batch {
+	b.failFast = true
+	return b
+}
+
+// Len returns the number of requests in the batch.
+func (b *Batch) Len() int {
+	return len(b.items)
+}
+
+// Execute runs the batch and returns a result per request, in the order they were added,
+// along with the first error by position, or in fail fast mode the error that stopped the batch.
+// Each request is sent as a copy, so the requests added to the batch are not changed; the copy keeps the
+// deadline and cancellation of the request context and is also canceled with the batch context.
+// Requests that are not started because the batch context is done have its error as their result.
+func (b *Batch) Execute(ctx context.Context) ([]BatchResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := b.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]BatchResult, len(b.items))
+	semaphore := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+
+	// failErr is the error that canceled a fail fast batch, rather than the cancellations it caused.
+	var failOnce sync.Once
+	var failErr error
+
+	for index, item := range b.items {
+		results[index].Request = item.request
+
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			results[index].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(index int, item batchItem) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			reqCtx, cancelReq := batchContext(ctx, item.request)
+			defer cancelReq()
+			req := item.request.clone().WithContext(reqCtx)
+			var meta *ResponseMeta
+			var err error
+			if item.deserialize != nil {
+				meta, err = req.Deserialized(item.deserialize)
+			} else {
+				meta, err = req.ExecuteWithMeta()
+			}
+			results[index].Meta = meta
+			results[index].Err = err
+			if err != nil && b.failFast {
+				failOnce.Do(func() {
+					failErr = err
+					cancel()
+				})
+			}
+		}(index, item)
+	}
+	wg.Wait()
+
+	if failErr != nil {
+		return results, failErr
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			return results, result.Err
+		}
+	}
+	return results, nil
+}
+
+// batchContext returns the context a batched request is sent with: its own context, also canceled when the batch is.
+func batchContext(batchCtx context.Context, req *Request) (context.Context, context.CancelFunc) {
+	if req.ctx == nil {
+		return context.WithCancel(batchCtx)
+	}
+	ctx, cancel := context.WithCancel(req.ctx)
+	go func() {
+		select {
+		case <-batchCtx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,162 @@
+package request
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func TestBatchCollectAll(t *testing.T) {
+	assert := assert.New(t)
+
+	var inFlight, maxInFlight int32
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		if r.URL.Query().Get("id") == "3" {
+			writeJSON(w, notFoundMeta(), statusOkObject())
+			return
+		}
+		writeJSON(w, okMeta(), testObject{Name: r.URL.Query().Get("id")})
+	})
+	defer ts.Close()
+
+	batch := NewBatch().WithConcurrency(2)
+	destinations := make([]testObject, 6)
+	for index := range destinations {
+		batch.AddJSON(New().WithURL(fmt.Sprintf("%s?id=%d", ts.URL, index)), &destinations[index])
+	}
+	assert.Equal(6, batch.Len())
+
+	results, err := batch.Execute(context.Background())
+	assert.Nil(err)
+	assert.Len(results, 6)
+	assert.True(atomic.LoadInt32(&maxInFlight) <= 2)
+	for index, result := range results {
+		assert.Nil(result.Err)
+		if index == 3 {
+			assert.Equal(http.StatusNotFound, result.Meta.StatusCode)
+			continue
+		}
+		assert.Equal(http.StatusOK, result.Meta.StatusCode)
+		assert.Equal(fmt.Sprintf("%d", index), destinations[index].Name)
+	}
+}
+
+func TestBatchFailFast(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("id") == "0" {
+			w.Write([]byte("not json"))
+			return
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	defer ts.Close()
+
+	batch := NewBatch().WithConcurrency(2).WithFailFast()
+	for index := 0; index < 4; index++ {
+		batch.AddJSON(New().WithURL(fmt.Sprintf("%s?id=%d", ts.URL, index)), &statusObject{})
+	}
+
+	started := time.Now()
+	results, err := batch.Execute(context.Background())
+	assert.NotNil(err)
+	assert.True(time.Since(started) < 500*time.Millisecond)
+	assert.NotNil(results[0].Err)
+	for _, result := range results[1:] {
+		assert.NotNil(result.Err)
+	}
+}
+
+func TestBatchContextCanceled(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := NewBatch().Add(New().WithURL("http://localhost:1/"), nil).Execute(ctx)
+	assert.Equal(context.Canceled, err)
+	assert.Equal(context.Canceled, results[0].Err)
+}
+
+func TestBatchFailFastReturnsTriggeringError(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("id") == "1" {
+			time.Sleep(10 * time.Millisecond)
+			w.Write([]byte("not json"))
+			return
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	defer ts.Close()
+
+	batch := NewBatch().WithConcurrency(2).WithFailFast()
+	for index := 0; index < 2; index++ {
+		batch.AddJSON(New().WithURL(fmt.Sprintf("%s?id=%d", ts.URL, index)), &statusObject{})
+	}
+
+	results, err := batch.Execute(context.Background())
+	assert.NotNil(err)
+	assert.NotNil(results[0].Err)
+	assert.Equal(results[1].Err, err)
+}
+
+func TestBatchDoesNotChangeRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer ts.Close()
+
+	req := New().WithURL(ts.URL)
+	_, err := NewBatch().AddJSON(req, &statusObject{}).Execute(context.Background())
+	assert.Nil(err)
+	assert.Equal(context.Background(), req.Context())
+
+	var status statusObject
+	assert.Nil(req.JSON(&status))
+	assert.Equal("ok!", status.Status)
+}
+
+func TestBatchKeepsRequestContext(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	results, err := NewBatch().Add(New().WithURL(ts.URL).WithContext(ctx), nil).Execute(context.Background())
+	assert.NotNil(err)
+	assert.NotNil(results[0].Err)
+	assert.True(time.Since(started) < 500*time.Millisecond)
+}
@@ -0,0 +1,226 @@
+package request
+
+import (
+	"encoding"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+const (
+	// TagStatus is the struct tag that marks a field to receive the response status code.
+	TagStatus = "status"
+)
+
+// JSONBind unmarshals the response as json to an object, then sets fields of the object tagged with
+// `header:"X-Name"` from the response headers and fields tagged with `status:""` to the status code.
+// See `BindResponseMeta` for the supported field types; header fields usually also want a `json:"-"` tag.
+func (hr *Request) JSONBind(destination interface{}) (*ResponseMeta, error) {
+	meta, err := hr.deserialize(newJSONDeserializer(destination))
+	if err != nil {
+		return meta, err
+	}
+	if err := BindResponseMeta(meta, destination); err != nil {
+		hr.logError(err, ErrorClassDecode)
+		return meta, wrap(err)
+	}
+	return meta, nil
+}
+
+// BindResponseMeta sets the fields of a struct tagged with `header:"X-Name"` from the response headers
+// and fields tagged with `status:""` to the response status code.
+//
+// Fields can be strings, bools, ints, uints, floats, `time.Time`, `time.Duration`, types that implement
+// `encoding.TextUnmarshaler`, pointers to any of these, or slices of them, which receive every value of the header.
+// Times are parsed with the layout from a `layout:"..."` tag, as unix seconds with the `unix` option,
+// or as http dates by default. Durations accept go duration strings or whole seconds, as in `Retry-After`.
+// Headers missing from the response leave their fields unchanged.
+func BindResponseMeta(meta *ResponseMeta, destination interface{}) error {
+	if meta == nil {
+		return nil
+	}
+	value := reflect.ValueOf(destination)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return exception.New("response bind destination must be a non-nil pointer")
+	}
+	value = indirect(value)
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	return bindStruct(value, meta)
+}
+
+func bindStruct(value reflect.Value, meta *ResponseMeta) error {
+	valueType := value.Type()
+	for index := 0; index < valueType.NumField(); index++ {
+		fieldType := valueType.Field(index)
+		fieldValue := value.Field(index)
+		headerTag, hasHeader := fieldType.Tag.Lookup(TagHeader)
+		_, hasStatus := fieldType.Tag.Lookup(TagStatus)
+
+		if fieldType.Anonymous && !hasHeader && !hasStatus {
+			if fieldValue.Kind() == reflect.Ptr && fieldValue.IsNil() {
+				if !fieldValue.CanSet() || fieldValue.Type().Elem().Kind() != reflect.Struct {
+					continue
+				}
+				fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+			}
+			if embedded := indirect(fieldValue); embedded.Kind() == reflect.Struct {
+				if err := bindStruct(embedded, meta); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if len(fieldType.PkgPath) > 0 {
+			continue
+		}
+
+		if hasStatus {
+			if err := bindValue(fieldValue, fieldType, tagOptions{}, strconv.Itoa(meta.StatusCode)); err != nil {
+				return err
+			}
+			continue
+		}
+		if !hasHeader || headerTag == "-" {
+			continue
+		}
+
+		name, options := parseTag(headerTag)
+		if isEmpty(name) {
+			name = fieldType.Name
+		}
+		values := meta.Headers[http.CanonicalHeaderKey(name)]
+		if len(values) == 0 {
+			continue
+		}
+		if err := bindValues(fieldValue, fieldType, options, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bindValues(value reflect.Value, field reflect.StructField, options tagOptions, values []string) error {
+	if value.Kind() != reflect.Slice || value.Type().Elem().Kind() == reflect.Uint8 {
+		return bindValue(value, field, options, values[0])
+	}
+
+	var elements []string
+	for _, headerValue := range values {
+		if options.comma {
+			for _, element := range strings.Split(headerValue, ",") {
+				elements = append(elements, strings.TrimSpace(element))
+			}
+		} else {
+			elements = append(elements, headerValue)
+		}
+	}
+
+	slice := reflect.MakeSlice(value.Type(), len(elements), len(elements))
+	for index, element := range elements {
+		if err := bindValue(slice.Index(index), field, options, element); err != nil {
+			return err
+		}
+	}
+	value.Set(slice)
+	return nil
+}
+
+func bindValue(value reflect.Value, field reflect.StructField, options tagOptions, raw string) error {
+	if value.Kind() == reflect.Ptr {
+		element := reflect.New(value.Type().Elem())
+		if err := bindValue(element.Elem(), field, options, raw); err != nil {
+			return err
+		}
+		value.Set(element)
+		return nil
+	}
+
+	switch value.Type() {
+	case timeType:
+		timestamp, err := parseBoundTime(field, options, raw)
+		if err != nil {
+			return bindError(field, raw, err)
+		}
+		value.Set(reflect.ValueOf(timestamp))
+		return nil
+	case durationType:
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			seconds, secondsErr := strconv.ParseInt(raw, 10, 64)
+			if secondsErr != nil {
+				return bindError(field, raw, err)
+			}
+			duration = time.Duration(seconds) * time.Second
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	if unmarshaler, isUnmarshaler := value.Addr().Interface().(encoding.TextUnmarshaler); isUnmarshaler {
+		if err := unmarshaler.UnmarshalText([]byte(raw)); err != nil {
+			return bindError(field, raw, err)
+		}
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return bindError(field, raw, err)
+		}
+		value.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return bindError(field, raw, err)
+		}
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(raw, 10, value.Type().Bits())
+		if err != nil {
+			return bindError(field, raw, err)
+		}
+		value.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return bindError(field, raw, err)
+		}
+		value.SetFloat(parsed)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.Uint8 {
+			return exception.Newf("cannot bind field %s of type %s", field.Name, value.Type())
+		}
+		value.SetBytes([]byte(raw))
+	default:
+		return exception.Newf("cannot bind field %s of type %s", field.Name, value.Type())
+	}
+	return nil
+}
+
+func parseBoundTime(field reflect.StructField, options tagOptions, raw string) (time.Time, error) {
+	if options.unix {
+		seconds, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	if layout := field.Tag.Get(TagLayout); !isEmpty(layout) {
+		return time.Parse(layout, raw)
+	}
+	return http.ParseTime(raw)
+}
+
+func bindError(field reflect.StructField, raw string, err error) error {
+	return exception.Newf("cannot bind %q to field %s: %v", raw, field.Name, err)
+}
@@ -0,0 +1,87 @@
+package request
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+type boundPage struct {
+	Items      []string      `json:"items"`
+	Status     int           `json:"-" status:""`
+	TotalCount int           `json:"-" header:"X-Total-Count"`
+	RequestID  *string       `json:"-" header:"X-Request-Id"`
+	LastMod    time.Time     `json:"-" header:"Last-Modified"`
+	Reset      time.Time     `json:"-" header:"X-RateLimit-Reset,unix"`
+	RetryAfter time.Duration `json:"-" header:"Retry-After"`
+	Vary       []string      `json:"-" header:"Vary,comma"`
+	Missing    string        `json:"-" header:"X-Missing"`
+}
+
+func TestJSONBind(t *testing.T) {
+	assert := assert.New(t)
+
+	lastModified := time.Date(2017, 02, 03, 4, 5, 6, 0, time.UTC)
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Total-Count", "42")
+		w.Header().Set("X-Request-Id", "req-1")
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		w.Header().Set("X-RateLimit-Reset", "1500000000")
+		w.Header().Set("Retry-After", "120")
+		w.Header().Add("Vary", "Accept, Origin")
+		w.Header().Add("Vary", "Cookie")
+		writeJSON(w, &ResponseMeta{StatusCode: http.StatusPartialContent}, map[string]interface{}{"items": []string{"a", "b"}})
+	})
+	defer ts.Close()
+
+	page := boundPage{Missing: "unchanged"}
+	meta, err := New().WithURL(ts.URL).JSONBind(&page)
+	assert.Nil(err)
+	assert.Equal(http.StatusPartialContent, meta.StatusCode)
+	assert.Equal([]string{"a", "b"}, page.Items)
+	assert.Equal(http.StatusPartialContent, page.Status)
+	assert.Equal(42, page.TotalCount)
+	assert.NotNil(page.RequestID)
+	assert.Equal("req-1", *page.RequestID)
+	assert.True(lastModified.Equal(page.LastMod))
+	assert.Equal(int64(1500000000), page.Reset.Unix())
+	assert.Equal(2*time.Minute, page.RetryAfter)
+	assert.Equal([]string{"Accept", "Origin", "Cookie"}, page.Vary)
+	assert.Equal("unchanged", page.Missing)
+}
+
+func TestBindResponseMetaInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	meta := &ResponseMeta{StatusCode: http.StatusOK, Headers: http.Header{"X-Total-Count": {"lots"}}}
+	var page boundPage
+	assert.NotNil(BindResponseMeta(meta, &page))
+	assert.NotNil(BindResponseMeta(meta, page))
+
+	var items []string
+	assert.Nil(BindResponseMeta(meta, &items))
+}
+
+func TestBindResponseMetaEmbedded(t *testing.T) {
+	assert := assert.New(t)
+
+	type rateLimit struct {
+		Code      int `status:""`
+		Remaining int `header:"X-RateLimit-Remaining"`
+	}
+	var value struct {
+		rateLimit
+		Total int `header:"X-Total-Count"`
+	}
+
+	meta := &ResponseMeta{StatusCode: http.StatusTooManyRequests, Headers: http.Header{
+		"X-Ratelimit-Remaining": {"7"},
+		"X-Total-Count":         {"42"},
+	}}
+	assert.Nil(BindResponseMeta(meta, &value))
+	assert.Equal(http.StatusTooManyRequests, value.Code)
+	assert.Equal(7, value.Remaining)
+	assert.Equal(42, value.Total)
+}
@@ -0,0 +1,257 @@
+package request
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	exception "github.com/blendlabs/go-exception"
+	logger "github.com/blendlabs/go-logger"
+)
+
+var (
+	// ErrCircuitOpen is returned when a request is rejected by an open circuit breaker.
+	ErrCircuitOpen = exception.New("request: circuit breaker is open")
+)
+
+// CircuitState is the state of a circuit.
+type CircuitState string
+
+const (
+	// CircuitClosed lets requests through and counts failures.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen rejects requests with `ErrCircuitOpen` until the cool down elapses.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single trial request through to decide whether to close or re-open.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+const (
+	// DefaultCircuitBreakerConsecutiveFailures is the default number of consecutive failures that open a circuit.
+	DefaultCircuitBreakerConsecutiveFailures = 5
+	// DefaultCircuitBreakerCoolDown is the default time a circuit stays open before a trial request.
+	DefaultCircuitBreakerCoolDown = 30 * time.Second
+)
+
+// NewCircuitBreaker returns a new circuit breaker that opens after
+// `DefaultCircuitBreakerConsecutiveFailures` consecutive failures to a host.
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		keyBy:               RequestKeyHost,
+		consecutiveFailures: DefaultCircuitBreakerConsecutiveFailures,
+		coolDown:            DefaultCircuitBreakerCoolDown,
+		circuits:            map[string]*circuit{},
+		now:                 time.Now,
+	}
+}
+
+// CircuitBreaker fails requests fast when a host or label keeps failing.
+// Transport errors and 5xx responses count as failures.
+// A breaker is safe to share between requests and goroutines.
+type CircuitBreaker struct {
+	sync.Mutex
+
+	keyBy               RequestKey
+	consecutiveFailures int
+	failureRate         float64
+	minRequests         int
+	window              time.Duration
+	coolDown            time.Duration
+
+	circuits map[string]*circuit
+	logger   *logger.Agent
+	now      func() time.Time
+}
+
+type circuit struct {
+	state               CircuitState
+	openedAt            time.Time
+	trialInFlight       bool
+	consecutiveFailures int
+	windowStart         time.Time
+	requests            int
+	failures            int
+}
+
+// WithKeyBy sets what requests share a circuit.
+func (cb *CircuitBreaker) WithKeyBy(key RequestKey) *CircuitBreaker {
+	cb.keyBy = key
+	return cb
+}
+
+// WithConsecutiveFailures sets the number of consecutive failures that open a circuit, or disables the check if zero.
+func (cb *CircuitBreaker) WithConsecutiveFailures(failures int) *CircuitBreaker {
+	cb.consecutiveFailures = failures
+	return cb
+}
+
+// WithFailureRate opens a circuit when the share of failed requests in a window reaches `rate`,
+// once at least `minRequests` have been made in that window.
+func (cb *CircuitBreaker) WithFailureRate(rate float64, minRequests int, window time.Duration) *CircuitBreaker {
+	cb.failureRate = rate
+	cb.minRequests = minRequests
+	cb.window = window
+	return cb
+}
+
+// WithCoolDown sets how long a circuit stays open before a trial request is let through.
+func (cb *CircuitBreaker) WithCoolDown(coolDown time.Duration) *CircuitBreaker {
+	cb.coolDown = coolDown
+	return cb
+}
+
+// WithLogger sets the logger state changes are reported to.
+// Without one, state changes are reported to the logger of the request that caused them.
+func (cb *CircuitBreaker) WithLogger(agent *logger.Agent) *CircuitBreaker {
+	cb.logger = agent
+	return cb
+}
+
+// State returns the current state of a circuit.
+func (cb *CircuitBreaker) State(key string) CircuitState {
+	cb.Lock()
+	defer cb.Unlock()
+	if c, hasCircuit := cb.circuits[key]; hasCircuit {
+		return cb.currentState(c)
+	}
+	return CircuitClosed
+}
+
+// States returns the current state of every circuit, i.e. for health endpoints.
+func (cb *CircuitBreaker) States() map[string]CircuitState {
+	cb.Lock()
+	defer cb.Unlock()
+	states := map[string]CircuitState{}
+	for key, c := range cb.circuits {
+		states[key] = cb.currentState(c)
+	}
+	return states
+}
+
+// Key returns the circuit key for a request, see `RequestKey.Key`.
+func (cb *CircuitBreaker) Key(req *Request) string {
+	return cb.keyBy.Key(req)
+}
+
+// currentState reports an open circuit whose cool down has elapsed as half-open.
+func (cb *CircuitBreaker) currentState(c *circuit) CircuitState {
+	if c.state == CircuitOpen && cb.now().Sub(c.openedAt) >= cb.coolDown {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+// allow returns `ErrCircuitOpen` if a request should be rejected.
+func (cb *CircuitBreaker) allow(key string, agent *logger.Agent) error {
+	cb.Lock()
+	defer cb.Unlock()
+
+	c, hasCircuit := cb.circuits[key]
+	if !hasCircuit {
+		c = &circuit{state: CircuitClosed, windowStart: cb.now()}
+		cb.circuits[key] = c
+	}
+
+	switch cb.currentState(c) {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		if c.trialInFlight {
+			return ErrCircuitOpen
+		}
+		if c.state != CircuitHalfOpen {
+			cb.transition(key, c, CircuitHalfOpen, agent)
+		}
+		c.trialInFlight = true
+	}
+	return nil
+}
+
+// release ends a request without counting it either way, freeing the trial slot of a half open circuit.
+func (cb *CircuitBreaker) release(key string) {
+	cb.Lock()
+	defer cb.Unlock()
+	if c, hasCircuit := cb.circuits[key]; hasCircuit && c.state == CircuitHalfOpen {
+		c.trialInFlight = false
+	}
+}
+
+// record counts the outcome of a request that was allowed through.
+func (cb *CircuitBreaker) record(key string, failed bool, agent *logger.Agent) {
+	cb.Lock()
+	defer cb.Unlock()
+
+	c, hasCircuit := cb.circuits[key]
+	if !hasCircuit {
+		return
+	}
+
+	if c.state == CircuitHalfOpen {
+		c.trialInFlight = false
+		if failed {
+			cb.transition(key, c, CircuitOpen, agent)
+		} else {
+			cb.transition(key, c, CircuitClosed, agent)
+		}
+		return
+	}
+	if c.state != CircuitClosed {
+		return
+	}
+
+	now := cb.now()
+	if cb.window > 0 && now.Sub(c.windowStart) >= cb.window {
+		c.windowStart = now
+		c.requests = 0
+		c.failures = 0
+	}
+	c.requests++
+	if failed {
+		c.failures++
+		c.consecutiveFailures++
+	} else {
+		c.consecutiveFailures = 0
+	}
+
+	if cb.consecutiveFailures > 0 && c.consecutiveFailures >= cb.consecutiveFailures {
+		cb.transition(key, c, CircuitOpen, agent)
+		return
+	}
+	if cb.failureRate > 0 && c.requests >= cb.minRequests && float64(c.failures)/float64(c.requests) >= cb.failureRate {
+		cb.transition(key, c, CircuitOpen, agent)
+	}
+}
+
+func (cb *CircuitBreaker) transition(key string, c *circuit, to CircuitState, agent *logger.Agent) {
+	from := c.state
+	c.state = to
+	c.consecutiveFailures = 0
+	c.requests = 0
+	c.failures = 0
+	c.windowStart = cb.now()
+	if to == CircuitOpen {
+		c.openedAt = cb.now()
+	}
+
+	if cb.logger != nil {
+		agent = cb.logger
+	}
+	if agent != nil {
+		agent.OnEvent(EventCircuitBreaker, key, from, to)
+	}
+}
+
+// WithCircuitBreaker sets a circuit breaker for the request.
+func (hr *Request) WithCircuitBreaker(breaker *CircuitBreaker) *Request {
+	hr.circuitBreaker = breaker
+	return hr
+}
+
+// isCircuitBreakerFailure returns if the outcome of a request says the host is unhealthy;
+// errors that are not transport errors, like a redirect policy refusing a redirect, do not.
+func isCircuitBreakerFailure(res *http.Response, err error) bool {
+	if err != nil {
+		return isTransportError(err)
+	}
+	return res != nil && res.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,165 @@
+package request
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func TestCircuitBreakerConsecutiveFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	calls := 0
+	healthy := false
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if healthy {
+			writeJSON(w, okMeta(), statusOkObject())
+			return
+		}
+		writeJSON(w, errorMeta(), statusOkObject())
+	})
+	defer ts.Close()
+
+	now := time.Date(2017, 01, 01, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker().WithConsecutiveFailures(2).WithCoolDown(time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	req := func() error {
+		var status statusObject
+		return New().WithURL(ts.URL).WithCircuitBreaker(breaker).JSON(&status)
+	}
+
+	assert.Nil(req())
+	assert.Nil(req())
+	key := New().WithURL(ts.URL).Host
+	assert.Equal(CircuitOpen, breaker.State(key))
+
+	assert.Equal(ErrCircuitOpen, req())
+	assert.Equal(2, calls)
+
+	now = now.Add(time.Minute)
+	assert.Equal(CircuitHalfOpen, breaker.State(key))
+	assert.Nil(req())
+	assert.Equal(3, calls)
+	assert.Equal(CircuitOpen, breaker.State(key))
+
+	now = now.Add(time.Minute)
+	healthy = true
+	assert.Nil(req())
+	assert.Equal(CircuitClosed, breaker.State(key))
+	assert.Equal(map[string]CircuitState{key: CircuitClosed}, breaker.States())
+}
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	assert := assert.New(t)
+
+	breaker := NewCircuitBreaker().WithConsecutiveFailures(0).WithFailureRate(0.5, 4, time.Minute).WithKeyBy(RequestKeyLabel)
+	req := New().WithURL("http://borrowers.internal/").WithLabel("borrowers")
+	key := breaker.Key(req)
+	assert.Equal("borrowers", key)
+
+	for _, failed := range []bool{true, false, true} {
+		assert.Nil(breaker.allow(key, nil))
+		breaker.record(key, failed, nil)
+	}
+	assert.Equal(CircuitClosed, breaker.State(key))
+
+	assert.Nil(breaker.allow(key, nil))
+	breaker.record(key, false, nil)
+	assert.Equal(CircuitOpen, breaker.State(key))
+}
+
+func TestCircuitBreakerHalfOpenSingleTrial(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2017, 01, 01, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker().WithConsecutiveFailures(1).WithCoolDown(time.Second)
+	breaker.now = func() time.Time { return now }
+
+	assert.Nil(breaker.allow("host", nil))
+	breaker.record("host", true, nil)
+	assert.Equal(ErrCircuitOpen, breaker.allow("host", nil))
+
+	now = now.Add(time.Second)
+	assert.Nil(breaker.allow("host", nil))
+	assert.Equal(ErrCircuitOpen, breaker.allow("host", nil))
+	breaker.record("host", false, nil)
+	assert.Nil(breaker.allow("host", nil))
+}
+
+func TestCircuitBreakerIgnoresCallerCancellation(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	defer ts.Close()
+
+	breaker := NewCircuitBreaker().WithConsecutiveFailures(1)
+	for x := 0; x < 3; x++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(5 * time.Millisecond)
+			cancel()
+		}()
+		assert.NotNil(New().WithURL(ts.URL).WithCircuitBreaker(breaker).WithContext(ctx).Execute())
+	}
+	assert.Equal(CircuitClosed, breaker.State(New().WithURL(ts.URL).Host))
+}
+
+func TestCircuitBreakerIgnoresCallerDeadline(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	defer ts.Close()
+
+	breaker := NewCircuitBreaker().WithConsecutiveFailures(1)
+	for x := 0; x < 3; x++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		assert.NotNil(New().WithURL(ts.URL).WithCircuitBreaker(breaker).WithContext(ctx).Execute())
+		cancel()
+	}
+	assert.Equal(CircuitClosed, breaker.State(New().WithURL(ts.URL).Host))
+}
+
+func TestCircuitBreakerKeysBalancedHosts(t *testing.T) {
+	assert := assert.New(t)
+
+	failing := mockEndpoint(errorMeta(), nil, nil)
+	defer failing.Close()
+	healthy := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer healthy.Close()
+
+	breaker := NewCircuitBreaker().WithConsecutiveFailures(1)
+	lb := NewLoadBalancer(hostOf(failing.URL), hostOf(healthy.URL))
+	for x := 0; x < 2; x++ {
+		New().WithURL("http://service/").WithLoadBalancer(lb).WithCircuitBreaker(breaker).Execute()
+	}
+	assert.Equal(CircuitOpen, breaker.State(hostOf(failing.URL)))
+	assert.Equal(CircuitClosed, breaker.State(hostOf(healthy.URL)))
+	assert.Equal(CircuitClosed, breaker.State("service"))
+}
+
+func TestCircuitBreakerIgnoresRedirectPolicyErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(redirectingEndpoint("/c"))
+	defer ts.Close()
+
+	breaker := NewCircuitBreaker().WithConsecutiveFailures(1)
+	for x := 0; x < 2; x++ {
+		err := New().WithURL(ts.URL + "/a").
+			WithCircuitBreaker(breaker).
+			WithRedirectPolicy(RedirectPolicy{MaxRedirects: 1}).
+			Execute()
+		assert.NotNil(err)
+		assert.NotEqual(ErrorClassCircuitOpen, ClassifyError(err))
+	}
+	assert.Equal(CircuitClosed, breaker.State(New().WithURL(ts.URL).Host))
+}
@@ -0,0 +1,117 @@
+package request
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	exception "github.com/blendlabs/go-exception"
+	logger "github.com/blendlabs/go-logger"
+)
+
+const (
+	// DefaultCertificateCheckInterval is the default interval between checks for changed certificate files.
+	DefaultCertificateCheckInterval = 5 * time.Second
+)
+
+// NewCertificateProvider returns a new certificate provider for a client certificate and key on disk.
+// It returns an error if the initial certificate cannot be loaded.
+func NewCertificateProvider(certPath, keyPath string) (*CertificateProvider, error) {
+	provider := &CertificateProvider{
+		certPath:      certPath,
+		keyPath:       keyPath,
+		checkInterval: DefaultCertificateCheckInterval,
+	}
+	if err := provider.Reload(); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// CertificateProvider serves a client certificate that is reloaded from disk when the files change.
+// When the files are checked is governed by the check interval; a failed reload keeps serving the
+// last good certificate and is reported to the logger, and is retried on the next check.
+type CertificateProvider struct {
+	sync.Mutex
+
+	certPath string
+	keyPath  string
+
+	checkInterval time.Duration
+	lastCheck     time.Time
+	certModTime   time.Time
+	keyModTime    time.Time
+	cert          *tls.Certificate
+
+	logger *logger.Agent
+}
+
+// WithCheckInterval sets how often the files are checked for changes.
+func (cp *CertificateProvider) WithCheckInterval(interval time.Duration) *CertificateProvider {
+	cp.Lock()
+	cp.checkInterval = interval
+	cp.Unlock()
+	return cp
+}
+
+// WithLogger sets the logger that reload failures are reported to.
+func (cp *CertificateProvider) WithLogger(agent *logger.Agent) *CertificateProvider {
+	cp.Lock()
+	cp.logger = agent
+	cp.Unlock()
+	return cp
+}
+
+// Certificate returns the current certificate, reloading it first if the files have changed.
+func (cp *CertificateProvider) Certificate() *tls.Certificate {
+	cp.Lock()
+	defer cp.Unlock()
+
+	now := time.Now()
+	if now.Sub(cp.lastCheck) >= cp.checkInterval {
+		cp.lastCheck = now
+		if err := cp.reloadIfChanged(); err != nil && cp.logger != nil {
+			cp.logger.Error(err)
+		}
+	}
+	return cp.cert
+}
+
+// GetClientCertificate implements `tls.Config.GetClientCertificate`.
+func (cp *CertificateProvider) GetClientCertificate(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return cp.Certificate(), nil
+}
+
+// Reload loads the certificate from disk regardless of whether the files have changed.
+func (cp *CertificateProvider) Reload() error {
+	cp.Lock()
+	defer cp.Unlock()
+	cp.certModTime = time.Time{}
+	cp.keyModTime = time.Time{}
+	return cp.reloadIfChanged()
+}
+
+func (cp *CertificateProvider) reloadIfChanged() error {
+	certInfo, err := os.Stat(cp.certPath)
+	if err != nil {
+		return exception.Wrap(err)
+	}
+	keyInfo, err := os.Stat(cp.keyPath)
+	if err != nil {
+		return exception.Wrap(err)
+	}
+	if certInfo.ModTime().Equal(cp.certModTime) && keyInfo.ModTime().Equal(cp.keyModTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cp.certPath, cp.keyPath)
+	if err != nil {
+		// the files may be mid-rotation; keep the old pair and mod times so the next check retries.
+		return exception.Wrap(err)
+	}
+	cp.cert = &cert
+	cp.certModTime = certInfo.ModTime()
+	cp.keyModTime = keyInfo.ModTime()
+	return nil
+}
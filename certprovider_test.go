@@ -0,0 +1,93 @@
+package request
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func writeCertificateFiles(certPath, keyPath, commonName string, modTime time.Time) error {
+	certPEM, keyPEM, err := selfSignedPEM(commonName)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(certPath, certPEM, 0600); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return err
+	}
+	if err := os.Chtimes(certPath, modTime, modTime); err != nil {
+		return err
+	}
+	return os.Chtimes(keyPath, modTime, modTime)
+}
+
+func TestCertificateProviderReloads(t *testing.T) {
+	assert := assert.New(t)
+
+	var commonName string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commonName = r.TLS.PeerCertificates[0].Subject.CommonName
+		writeJSON(w, okMeta(), statusOkObject())
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "go-request")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	certPath := filepath.Join(dir, "client.crt")
+	keyPath := filepath.Join(dir, "client.key")
+
+	now := time.Now()
+	assert.Nil(writeCertificateFiles(certPath, keyPath, "first", now))
+
+	provider, err := NewCertificateProvider(certPath, keyPath)
+	assert.Nil(err)
+	provider.WithCheckInterval(0)
+
+	transport, err := New().WithVerifyTLS(false).WithClientTLSCertProvider(provider).Transport()
+	assert.Nil(err)
+
+	assert.Nil(New().WithURL(ts.URL).WithTransport(transport).Execute())
+	assert.Equal("first", commonName)
+
+	assert.Nil(writeCertificateFiles(certPath, keyPath, "second", now.Add(time.Minute)))
+	assert.Nil(New().WithURL(ts.URL).WithTransport(transport).Execute())
+	assert.Equal("second", commonName)
+
+	// a half written rotation keeps serving the last good pair.
+	assert.Nil(ioutil.WriteFile(keyPath, []byte("garbage"), 0600))
+	assert.Nil(os.Chtimes(keyPath, now.Add(2*time.Minute), now.Add(2*time.Minute)))
+	assert.Nil(New().WithURL(ts.URL).WithTransport(transport).Execute())
+	assert.Equal("second", commonName)
+}
+
+func TestWithClientTLSCertReload(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-request")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	certPath := filepath.Join(dir, "client.crt")
+	keyPath := filepath.Join(dir, "client.key")
+	assert.Nil(writeCertificateFiles(certPath, keyPath, "first", time.Now()))
+
+	transport, err := New().WithClientTLSCert(certPath).WithClientTLSKey(keyPath).WithClientTLSCertReload().Transport()
+	assert.Nil(err)
+	assert.Empty(transport.TLSClientConfig.Certificates)
+	assert.NotNil(transport.TLSClientConfig.GetClientCertificate)
+
+	cert, err := transport.TLSClientConfig.GetClientCertificate(nil)
+	assert.Nil(err)
+	assert.NotNil(cert)
+}
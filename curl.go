@@ -0,0 +1,115 @@
+package request
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+// Curl returns the request as a copy-pasteable curl command.
+// Remarks: posted files are left out, as `Request()` does not send them; the command sends the same body it does.
+func (hr *Request) Curl() (string, error) {
+	if hr.err != nil {
+		return "", hr.err
+	}
+	if !isEmpty(hr.PathTemplate) {
+		if _, _, err := hr.expandPath(); err != nil {
+			return "", err
+		}
+	}
+	if len(hr.Body) > 0 && len(hr.PostData) > 0 {
+		return "", exception.New("Cant set both a body and have post data.")
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	buffer.WriteString("curl -X ")
+	buffer.WriteString(shellQuote(hr.Verb))
+	buffer.WriteRune(' ')
+	buffer.WriteString(shellQuote(hr.URL().String()))
+
+	headers := hr.Headers()
+	for _, key := range sortedKeys(headers) {
+		for _, value := range headers[key] {
+			buffer.WriteString(" -H ")
+			buffer.WriteString(shellQuote(key + ": " + value))
+		}
+	}
+
+	if !isEmpty(hr.BasicAuthUsername) {
+		buffer.WriteString(" -u ")
+		buffer.WriteString(shellQuote(hr.BasicAuthUsername + ":" + hr.BasicAuthPassword))
+	}
+
+	if len(hr.Cookies) > 0 {
+		cookies := make([]string, 0, len(hr.Cookies))
+		for _, cookie := range hr.Cookies {
+			cookies = append(cookies, cookie.Name+"="+cookie.Value)
+		}
+		buffer.WriteString(" -b ")
+		buffer.WriteString(shellQuote(strings.Join(cookies, "; ")))
+	}
+
+	resolves := make([]string, 0, len(hr.HostOverrides))
+	for host, ip := range hr.HostOverrides {
+		resolves = append(resolves, curlResolve(hr, host, ip))
+	}
+	sort.Strings(resolves)
+	for _, resolve := range resolves {
+		buffer.WriteString(" --resolve ")
+		buffer.WriteString(shellQuote(resolve))
+	}
+	if !isEmpty(hr.UnixSocketPath) {
+		buffer.WriteString(" --unix-socket ")
+		buffer.WriteString(shellQuote(hr.UnixSocketPath))
+	}
+	if hr.ProxyURL != nil {
+		buffer.WriteString(" -x ")
+		buffer.WriteString(shellQuote(hr.ProxyURL.String()))
+	}
+	if len(hr.ProxyBypass) > 0 {
+		buffer.WriteString(" --noproxy ")
+		buffer.WriteString(shellQuote(strings.Join(hr.ProxyBypass, ",")))
+	}
+
+	if body := hr.PostBody(); len(body) > 0 {
+		buffer.WriteString(" --data-binary ")
+		buffer.WriteString(shellQuote(string(body)))
+	}
+
+	return buffer.String(), nil
+}
+
+// shellQuote wraps a value in single quotes so a posix shell passes it through verbatim.
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+func sortedKeys(values map[string][]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// curlResolve formats a host override as a `--resolve host:port:addr` value.
+func curlResolve(hr *Request, host, ip string) string {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		port := hr.URL().Port()
+		if isEmpty(port) {
+			port = "80"
+			if hr.URL().Scheme == "https" {
+				port = "443"
+			}
+		}
+		host = net.JoinHostPort(host, port)
+	}
+	if strings.Contains(ip, ":") {
+		ip = "[" + ip + "]"
+	}
+	return host + ":" + ip
+}
@@ -0,0 +1,62 @@
+package request
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func TestCurl(t *testing.T) {
+	assert := assert.New(t)
+
+	curl, err := New().AsPost().
+		WithURL("http://localhost:5001/api/v1/borrowers?foo=bar").
+		WithHeader("X-Deployment", "test").
+		WithBasicAuth("user", "pass").
+		WithCookie(&http.Cookie{Name: "session", Value: "abc"}).
+		WithPostBody([]byte(`{"name":"o'brien"}`)).
+		WithContentType("application/json").
+		Curl()
+	assert.Nil(err)
+	assert.Equal(`curl -X 'POST' 'http://localhost:5001/api/v1/borrowers?foo=bar'`+
+		` -H 'Content-Type: application/json' -H 'X-Deployment: test'`+
+		` -u 'user:pass' -b 'session=abc'`+
+		` --data-binary '{"name":"o'\''brien"}'`, curl)
+}
+
+func TestCurlPostData(t *testing.T) {
+	assert := assert.New(t)
+
+	curl, err := New().AsPost().WithURL("http://localhost/").WithPostData("foo", "bar baz").Curl()
+	assert.Nil(err)
+	assert.Equal(`curl -X 'POST' 'http://localhost/' -H 'Content-Type: application/x-www-form-urlencoded' --data-binary 'foo=bar+baz'`, curl)
+}
+
+func TestCurlPostedFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	contents := bytes.NewBufferString("pdf")
+	curl, err := New().AsPost().WithURL("http://localhost/upload").
+		WithPostData("kind", "w2").
+		WithPostedFile("document", `/tmp/w2 "2017".pdf`, contents).
+		Curl()
+	assert.Nil(err)
+	assert.Equal(`curl -X 'POST' 'http://localhost/upload' -H 'Content-Type: application/x-www-form-urlencoded' --data-binary 'kind=w2'`, curl)
+	assert.Equal("pdf", contents.String())
+}
+
+func TestCurlPathTemplateError(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := New().WithURL("http://localhost/").WithPathTemplate("/users/{id}").WithPathParam("id", "..").Curl()
+	assert.NotNil(err)
+}
+
+func TestCurlBodyAndPostData(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := New().AsPost().WithURL("http://localhost/").WithPostData("foo", "bar").WithPostBody([]byte("baz")).Curl()
+	assert.NotNil(err)
+}
@@ -0,0 +1,198 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+var (
+	errDedupPanic = exception.New("request: the shared request of a dedup group panicked")
+)
+
+// NewDedupGroup returns a new dedup group.
+func NewDedupGroup() *DedupGroup {
+	return &DedupGroup{
+		calls: map[string]*dedupCall{},
+	}
+}
+
+// DedupGroup collapses identical requests made at the same time into a single network call.
+// The first request makes the call and reads the whole response body; the others wait for it
+// and each receive their own copy of the response, so every caller can deserialize it independently.
+type DedupGroup struct {
+	sync.Mutex
+	calls map[string]*dedupCall
+}
+
+type dedupCall struct {
+	done chan struct{}
+	res  *http.Response
+	body []byte
+	err  error
+	// canceled is set if the call failed because the context of the request that made it was done.
+	canceled bool
+}
+
+// Do runs `fn` for the key unless a call for the key is already in flight, in which case it waits
+// for that call and returns a copy of its response.
+// Waiters stop waiting when `ctx` is done. If the call fails because the context of the request that
+// made it is done, the error is not passed on; a waiter makes the call itself instead.
+func (dg *DedupGroup) Do(ctx context.Context, key string, fn func() (*http.Response, error)) (*http.Response, error) {
+	for {
+		dg.Lock()
+		call, inFlight := dg.calls[key]
+		if !inFlight {
+			break
+		}
+		dg.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-call.done:
+		}
+		if !call.canceled {
+			return call.response()
+		}
+	}
+
+	// the error stays set for the waiters if `fn` panics.
+	call := &dedupCall{done: make(chan struct{}), err: errDedupPanic}
+	dg.calls[key] = call
+	dg.Unlock()
+
+	defer func() {
+		dg.Lock()
+		delete(dg.calls, key)
+		dg.Unlock()
+		close(call.done)
+	}()
+
+	call.res, call.err = fn()
+	if call.err == nil && call.res != nil && call.res.Body != nil {
+		call.body, call.err = ioutil.ReadAll(call.res.Body)
+		call.res.Body.Close()
+	}
+	call.canceled = call.err != nil && ctx.Err() != nil
+	return call.response()
+}
+
+// response returns a copy of the shared response with its own body reader.
+func (dc *dedupCall) response() (*http.Response, error) {
+	if dc.err != nil || dc.res == nil {
+		return dc.res, dc.err
+	}
+	res := *dc.res
+	res.Header = cloneHeader(dc.res.Header)
+	res.Body = ioutil.NopCloser(bytes.NewReader(dc.body))
+	res.ContentLength = int64(len(dc.body))
+	return &res, nil
+}
+
+// WithDedup collapses the request with identical requests in the group that are in flight at the same time.
+// Requests are identical if they have the same `DedupKey`.
+// Requests with a custom dialer or a create transport hook are never collapsed, as the functions cannot be compared.
+func (hr *Request) WithDedup(group *DedupGroup) *Request {
+	hr.dedupGroup = group
+	return hr
+}
+
+// DedupKey returns a key identifying the request by its verb, url, headers, credentials, cookies and body,
+// and by how it connects: its unix socket, transport, dns cache, tls settings, client certificate, proxy and host overrides.
+// Remarks: unlike `Hash` it includes everything that can change the response.
+func (hr *Request) DedupKey() string {
+	h := sha256.New()
+	h.Write([]byte(hr.Verb))
+	h.Write([]byte{0})
+	h.Write([]byte(hr.URL().String()))
+	h.Write([]byte{0})
+	headers := hr.Headers()
+	for _, key := range sortedKeys(headers) {
+		for _, value := range headers[key] {
+			h.Write([]byte(key + ": " + value))
+			h.Write([]byte{0})
+		}
+	}
+	h.Write([]byte(hr.BasicAuthUsername + ":" + hr.BasicAuthPassword))
+	h.Write([]byte{0})
+	for _, cookie := range hr.Cookies {
+		h.Write([]byte(cookie.Name + "=" + cookie.Value))
+		h.Write([]byte{0})
+	}
+	h.Write(hr.PostBody())
+	h.Write([]byte{0})
+	hr.writeTransportIdentity(h)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeTransportIdentity writes the settings that decide which connection the request is sent over,
+// so requests to the same url over different sockets or with different identities are not collapsed.
+func (hr *Request) writeTransportIdentity(h io.Writer) {
+	fmt.Fprintf(h, "unix=%s\x00", hr.UnixSocketPath)
+	if hr.transport != nil {
+		fmt.Fprintf(h, "transport=%p\x00", hr.transport)
+	}
+	if hr.dnsCache != nil {
+		fmt.Fprintf(h, "dns=%p\x00", hr.dnsCache)
+	}
+	fmt.Fprintf(h, "tls=%t|%s|%d\x00", hr.TLSSkipVerify, hr.TLSServerName, hr.TLSMinVersion)
+	if hr.TLSRootCAs != nil {
+		fmt.Fprintf(h, "roots=%p\x00", hr.TLSRootCAs)
+	}
+	for _, pin := range hr.TLSPinnedPublicKeys {
+		h.Write([]byte("pin="))
+		h.Write(pin)
+		h.Write([]byte{0})
+	}
+	fmt.Fprintf(h, "cert=%s|%s\x00", hr.TLSClientCertPath, hr.TLSClientKeyPath)
+	if hr.TLSClientCert != nil {
+		for _, certificate := range hr.TLSClientCert.Certificate {
+			h.Write(certificate)
+		}
+		h.Write([]byte{0})
+	}
+	if hr.certificateProvider != nil {
+		fmt.Fprintf(h, "provider=%p\x00", hr.certificateProvider)
+	}
+	if hr.ProxyURL != nil {
+		fmt.Fprintf(h, "proxy=%s\x00", hr.ProxyURL.String())
+	}
+	for _, host := range hr.ProxyBypass {
+		fmt.Fprintf(h, "bypass=%s\x00", host)
+	}
+	overrides := make([]string, 0, len(hr.HostOverrides))
+	for host := range hr.HostOverrides {
+		overrides = append(overrides, host)
+	}
+	sort.Strings(overrides)
+	for _, host := range overrides {
+		fmt.Fprintf(h, "override=%s=%s\x00", host, hr.HostOverrides[host])
+	}
+}
+
+// canDedup returns if the request can be collapsed with others, which needs every setting that
+// changes the response to be part of its `DedupKey`.
+func (hr *Request) canDedup() bool {
+	return hr.dedupGroup != nil && hr.dialer == nil && hr.createTransportHandler == nil
+}
+
+func cloneHeader(header http.Header) http.Header {
+	if header == nil {
+		return nil
+	}
+	cloned := http.Header{}
+	for key, values := range header {
+		cloned[key] = append([]string(nil), values...)
+	}
+	return cloned
+}
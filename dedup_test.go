@@ -0,0 +1,231 @@
+package request
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func TestDedupGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	var calls, requests int32
+	release := make(chan struct{})
+	returnedObject := newTestObject()
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		writeJSON(w, okMeta(), returnedObject)
+	})
+	defer ts.Close()
+
+	group := NewDedupGroup()
+	destinations := make([]testObject, 10)
+	metas := make([]*ResponseMeta, len(destinations))
+	errs := make([]error, len(destinations))
+
+	wg := sync.WaitGroup{}
+	for index := range destinations {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			metas[index], errs[index] = New().WithURL(ts.URL).WithDedup(group).
+				OnRequest(func(*Meta) { atomic.AddInt32(&requests, 1) }).
+				JSONWithMeta(&destinations[index])
+		}(index)
+	}
+	for atomic.LoadInt32(&requests) < int32(len(destinations)) {
+		time.Sleep(time.Millisecond)
+	}
+	waitForDedupWaiters()
+	close(release)
+	wg.Wait()
+
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+	for index := range destinations {
+		assert.Nil(errs[index])
+		assert.Equal(http.StatusOK, metas[index].StatusCode)
+		assert.Equal(returnedObject, destinations[index])
+	}
+
+	assert.Nil(New().WithURL(ts.URL).WithDedup(group).Execute())
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestDedupKey(t *testing.T) {
+	assert := assert.New(t)
+
+	base := func() *Request {
+		return New().AsPost().WithURL("http://localhost/api?foo=bar").WithPostBody([]byte("body"))
+	}
+	assert.Equal(base().DedupKey(), base().DedupKey())
+	assert.Equal(base().Hash(), base().WithHeader("X-Tenant", "a").Hash())
+	assert.NotEqual(base().DedupKey(), base().WithHeader("X-Tenant", "a").DedupKey())
+	assert.NotEqual(base().DedupKey(), base().WithPostBody([]byte("other")).DedupKey())
+	assert.NotEqual(base().DedupKey(), base().WithBasicAuth("user", "pass").DedupKey())
+	assert.NotEqual(base().DedupKey(), base().WithUnixSocket("/tmp/a.sock").DedupKey())
+	assert.NotEqual(base().WithUnixSocket("/tmp/a.sock").DedupKey(), base().WithUnixSocket("/tmp/b.sock").DedupKey())
+	assert.NotEqual(base().DedupKey(), base().WithClientTLSCert("client.crt").WithClientTLSKey("client.key").DedupKey())
+	assert.NotEqual(base().DedupKey(), base().WithHostOverride("localhost", "10.0.0.1").DedupKey())
+	assert.NotEqual(base().DedupKey(), base().WithVerifyTLS(false).DedupKey())
+	assert.NotEqual(base().DedupKey(), base().WithRootCAs(x509.NewCertPool()).DedupKey())
+	assert.NotEqual(base().DedupKey(), base().WithPinnedPublicKeys("sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=").DedupKey())
+	assert.NotEqual(base().DedupKey(), base().WithTLSServerName("other").DedupKey())
+	assert.NotEqual(base().DedupKey(), base().WithTLSMinVersion(tls.VersionTLS12).DedupKey())
+	assert.NotEqual(base().DedupKey(), base().WithTransport(&http.Transport{}).DedupKey())
+	assert.NotEqual(base().DedupKey(), base().WithDNSCache(NewDNSCache(time.Minute)).DedupKey())
+
+	group := NewDedupGroup()
+	assert.True(base().WithDedup(group).canDedup())
+	assert.False(base().WithDedup(group).WithDialer((&net.Dialer{}).DialContext).canDedup())
+	assert.False(base().WithDedup(group).OnCreateTransport(func(*url.URL, *http.Transport) {}).canDedup())
+}
+
+func TestDedupWaiterErrorsAreLogged(t *testing.T) {
+	assert := assert.New(t)
+
+	release := make(chan struct{})
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		writeJSON(w, okMeta(), statusOkObject())
+	})
+	defer ts.Close()
+	defer close(release)
+
+	group := NewDedupGroup()
+	go New().WithURL(ts.URL).WithDedup(group).Execute()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var logged error
+	err := New().WithURL(ts.URL).WithDedup(group).WithContext(ctx).
+		OnError(func(_ *Meta, err error) { logged = err }).
+		Execute()
+	assert.NotNil(err)
+	assert.Equal(context.DeadlineExceeded, logged)
+}
+
+func TestDedupGroupPanic(t *testing.T) {
+	assert := assert.New(t)
+
+	group := NewDedupGroup()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go func() {
+		defer func() { recover() }()
+		group.Do(context.Background(), "key", func() (*http.Response, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	done := make(chan error)
+	go func() {
+		_, err := group.Do(context.Background(), "key", func() (*http.Response, error) {
+			return nil, nil
+		})
+		done <- err
+	}()
+	waitForDedupWaiters()
+	close(release)
+
+	select {
+	case err := <-done:
+		assert.NotNil(err)
+	case <-time.After(time.Second):
+		assert.FailNow("waiter blocked after the shared call panicked")
+	}
+
+	group.Lock()
+	assert.Empty(group.calls)
+	group.Unlock()
+}
+
+func TestDedupGroupWaiterContext(t *testing.T) {
+	assert := assert.New(t)
+
+	group := NewDedupGroup()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	go group.Do(context.Background(), "key", func() (*http.Response, error) {
+		close(started)
+		<-release
+		return nil, nil
+	})
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := group.Do(ctx, "key", func() (*http.Response, error) {
+			return nil, nil
+		})
+		done <- err
+	}()
+	waitForDedupWaiters()
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.Equal(context.Canceled, err)
+	case <-time.After(time.Second):
+		assert.FailNow("waiter ignored its own context")
+	}
+}
+
+func TestDedupGroupLeaderCanceled(t *testing.T) {
+	assert := assert.New(t)
+
+	group := NewDedupGroup()
+	started := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	leaderDone := make(chan error)
+	go func() {
+		_, err := group.Do(ctx, "key", func() (*http.Response, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		leaderDone <- err
+	}()
+	<-started
+
+	done := make(chan error)
+	go func() {
+		_, err := group.Do(context.Background(), "key", func() (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		})
+		done <- err
+	}()
+	waitForDedupWaiters()
+	cancel()
+
+	assert.Equal(context.Canceled, <-leaderDone)
+	select {
+	case err := <-done:
+		assert.Nil(err)
+	case <-time.After(time.Second):
+		assert.FailNow("waiter did not make the call after the leader was canceled")
+	}
+}
+
+// waitForDedupWaiters gives callers that have been started time to join the call in flight.
+func waitForDedupWaiters() {
+	time.Sleep(50 * time.Millisecond)
+}
@@ -0,0 +1,95 @@
+package request
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func TestWithUnixSocket(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-request")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "sidecar.sock")
+	listener, err := net.Listen("unix", socketPath)
+	assert.Nil(err)
+	defer listener.Close()
+
+	var host string
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		writeJSON(w, okMeta(), statusOkObject())
+	}))
+
+	var status statusObject
+	err = New().WithUnixSocket(socketPath).WithHost("sidecar").WithPath("/status").JSON(&status)
+	assert.Nil(err)
+	assert.Equal("ok!", status.Status)
+	assert.Equal("sidecar", host)
+}
+
+func TestWithUnixSocketIgnoresEnvironmentProxy(t *testing.T) {
+	assert := assert.New(t)
+
+	previous, hadPrevious := os.LookupEnv("HTTP_PROXY")
+	os.Setenv("HTTP_PROXY", "http://127.0.0.1:1")
+	defer func() {
+		if hadPrevious {
+			os.Setenv("HTTP_PROXY", previous)
+		} else {
+			os.Unsetenv("HTTP_PROXY")
+		}
+	}()
+
+	dir, err := ioutil.TempDir("", "go-request")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "sidecar.sock")
+	listener, err := net.Listen("unix", socketPath)
+	assert.Nil(err)
+	defer listener.Close()
+
+	var requestURI string
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.RequestURI
+		writeJSON(w, okMeta(), statusOkObject())
+	}))
+
+	req := New().WithUnixSocket(socketPath).WithHost("sidecar").WithPath("/status")
+	transport, err := req.Transport()
+	assert.Nil(err)
+	assert.Nil(transport.Proxy)
+
+	var status statusObject
+	assert.Nil(req.JSON(&status))
+	assert.Equal("ok!", status.Status)
+	assert.Equal("/status", requestURI)
+}
+
+func TestWithDialer(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer ts.Close()
+
+	var dialedAddr string
+	dialer := &net.Dialer{}
+	var status statusObject
+	err := New().WithURL("http://logical.service/").WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialedAddr = addr
+		return dialer.DialContext(ctx, network, ts.Listener.Addr().String())
+	}).JSON(&status)
+	assert.Nil(err)
+	assert.Equal("ok!", status.Status)
+	assert.Equal("logical.service:80", dialedAddr)
+}
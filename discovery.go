@@ -0,0 +1,236 @@
+package request
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+const (
+	// SchemeSRV is a url scheme that resolves the host with a dns srv lookup and connects over http.
+	SchemeSRV = "srv"
+	// SchemeSRVHTTPS is a url scheme that resolves the host with a dns srv lookup and connects over https.
+	SchemeSRVHTTPS = "srv+https"
+
+	// DefaultServiceDiscoveryTTL is the default time srv records are cached for.
+	DefaultServiceDiscoveryTTL = 30 * time.Second
+)
+
+var (
+	// DefaultServiceDiscovery resolves `srv://` urls for requests without `WithServiceDiscovery`.
+	DefaultServiceDiscovery = NewServiceDiscovery(net.DefaultResolver)
+)
+
+// SRVResolver looks up dns srv records; it is implemented by `*net.Resolver`.
+type SRVResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// SRVTTLResolver is implemented by srv resolvers that also report how long the records they return may be cached for.
+// `*net.Resolver` does not report ttls, so its records are cached for the service discovery ttl.
+type SRVTTLResolver interface {
+	LookupSRVWithTTL(ctx context.Context, service, proto, name string) ([]*net.SRV, time.Duration, error)
+}
+
+// NewServiceDiscovery returns a new service discovery for a resolver.
+// To use a specific dns server, i.e. in tests, pass a `*net.Resolver` with a custom `Dial`.
+func NewServiceDiscovery(resolver SRVResolver) *ServiceDiscovery {
+	return &ServiceDiscovery{
+		resolver: resolver,
+		ttl:      DefaultServiceDiscoveryTTL,
+		cache:    map[string]srvCacheEntry{},
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:      time.Now,
+	}
+}
+
+// ServiceDiscovery resolves request hosts from dns srv records, honoring record priority and weight.
+// It is safe to share between requests and goroutines.
+type ServiceDiscovery struct {
+	sync.Mutex
+
+	resolver SRVResolver
+	ttl      time.Duration
+	cache    map[string]srvCacheEntry
+	random   *rand.Rand
+	now      func() time.Time
+}
+
+type srvCacheEntry struct {
+	records []*net.SRV
+	expires time.Time
+}
+
+// WithTTL sets how long records are cached for when the resolver does not report their ttl, see `SRVTTLResolver`.
+func (sd *ServiceDiscovery) WithTTL(ttl time.Duration) *ServiceDiscovery {
+	sd.Lock()
+	sd.ttl = ttl
+	sd.Unlock()
+	return sd
+}
+
+// Resolve returns a `host:port` target for a srv name, i.e. `_http._tcp.borrowers.internal`.
+// Targets are chosen from the records with the lowest priority at random, in proportion to their weights.
+func (sd *ServiceDiscovery) Resolve(ctx context.Context, name string) (string, error) {
+	targets, err := sd.ResolveAll(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return targets[0], nil
+}
+
+// ResolveAll returns every `host:port` target for a srv name in the order they should be tried:
+// by priority, and within a priority at random in proportion to their weights.
+func (sd *ServiceDiscovery) ResolveAll(ctx context.Context, name string) ([]string, error) {
+	records, err := sd.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	sd.Lock()
+	ordered := orderSRV(records, sd.random)
+	sd.Unlock()
+	if len(ordered) == 0 {
+		return nil, exception.Newf("request: no srv records for %s", name)
+	}
+	targets := make([]string, len(ordered))
+	for index, record := range ordered {
+		targets[index] = net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+	}
+	return targets, nil
+}
+
+func (sd *ServiceDiscovery) lookup(ctx context.Context, name string) ([]*net.SRV, error) {
+	sd.Lock()
+	entry, cached := sd.cache[name]
+	sd.Unlock()
+	if cached && sd.now().Before(entry.expires) {
+		return entry.records, nil
+	}
+
+	var records []*net.SRV
+	var ttl time.Duration
+	var err error
+	if ttlResolver, reportsTTL := sd.resolver.(SRVTTLResolver); reportsTTL {
+		records, ttl, err = ttlResolver.LookupSRVWithTTL(ctx, "", "", name)
+	} else {
+		_, records, err = sd.resolver.LookupSRV(ctx, "", "", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sd.Lock()
+	if ttl <= 0 {
+		ttl = sd.ttl
+	}
+	sd.cache[name] = srvCacheEntry{records: records, expires: sd.now().Add(ttl)}
+	sd.Unlock()
+	return records, nil
+}
+
+// orderSRV orders records as described in rfc 2782, by picking the next record from those left with `selectSRV`.
+func orderSRV(records []*net.SRV, random *rand.Rand) []*net.SRV {
+	remaining := append([]*net.SRV(nil), records...)
+	ordered := make([]*net.SRV, 0, len(records))
+	for len(remaining) > 0 {
+		record := selectSRV(remaining, random)
+		ordered = append(ordered, record)
+		for index, candidate := range remaining {
+			if candidate == record {
+				remaining = append(remaining[:index], remaining[index+1:]...)
+				break
+			}
+		}
+	}
+	return ordered
+}
+
+// selectSRV picks a record as described in rfc 2782.
+func selectSRV(records []*net.SRV, random *rand.Rand) *net.SRV {
+	var candidates []*net.SRV
+	for _, record := range records {
+		if len(candidates) == 0 || record.Priority < candidates[0].Priority {
+			candidates = []*net.SRV{record}
+		} else if record.Priority == candidates[0].Priority {
+			candidates = append(candidates, record)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, record := range candidates {
+		total += int(record.Weight)
+	}
+	if total == 0 {
+		return candidates[random.Intn(len(candidates))]
+	}
+	pick := random.Intn(total)
+	for _, record := range candidates {
+		pick -= int(record.Weight)
+		if pick < 0 {
+			return record
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// WithServiceDiscovery resolves the request host as a dns srv name with the given service discovery.
+// Urls with the `srv://` or `srv+https://` schemes use `DefaultServiceDiscovery` without it.
+func (hr *Request) WithServiceDiscovery(discovery *ServiceDiscovery) *Request {
+	hr.serviceDiscovery = discovery
+	return hr
+}
+
+func (hr *Request) getServiceDiscovery() *ServiceDiscovery {
+	if hr.serviceDiscovery != nil {
+		return hr.serviceDiscovery
+	}
+	if hr.Scheme == SchemeSRV || hr.Scheme == SchemeSRVHTTPS {
+		return DefaultServiceDiscovery
+	}
+	return nil
+}
+
+// resolveService returns the targets for the request host from service discovery, in the order they should be tried.
+func (hr *Request) resolveService() ([]string, error) {
+	discovery := hr.getServiceDiscovery()
+	if discovery == nil {
+		return nil, nil
+	}
+	return discovery.ResolveAll(hr.Context(), hr.Host)
+}
+
+// discoveredResponse makes the request against the targets from service discovery,
+// moving on to the next target when a connection cannot be made.
+func (hr *Request) discoveredResponse(targets []string) (*http.Response, error) {
+	for index, target := range targets {
+		hr.resolvedHost = target
+		res, err := hr.roundTrip()
+		if err != nil && index < len(targets)-1 && hr.Context().Err() == nil && isTransportError(err) && isConnectionError(err) {
+			continue
+		}
+		return res, err
+	}
+	return nil, nil
+}
+
+// urlScheme returns the scheme requests are sent with.
+func (hr *Request) urlScheme() string {
+	switch hr.Scheme {
+	case SchemeSRV:
+		return "http"
+	case SchemeSRVHTTPS:
+		return "https"
+	}
+	return hr.Scheme
+}
@@ -0,0 +1,226 @@
+package request
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+type fakeSRVResolver struct {
+	records map[string][]*net.SRV
+	lookups int
+}
+
+func (fr *fakeSRVResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	fr.lookups++
+	records, hasRecords := fr.records[name]
+	if !hasRecords {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return name, records, nil
+}
+
+type fakeTTLSRVResolver struct {
+	fakeSRVResolver
+	ttl time.Duration
+}
+
+func (fr *fakeTTLSRVResolver) LookupSRVWithTTL(ctx context.Context, service, proto, name string) ([]*net.SRV, time.Duration, error) {
+	_, records, err := fr.LookupSRV(ctx, service, proto, name)
+	return records, fr.ttl, err
+}
+
+func TestServiceDiscoveryRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(hostOf(ts.URL))
+	portNumber, _ := strconv.Atoi(port)
+
+	resolver := &fakeSRVResolver{records: map[string][]*net.SRV{
+		"_http._tcp.borrowers.internal": {{Target: "127.0.0.1.", Port: uint16(portNumber), Priority: 10, Weight: 1}},
+	}}
+	discovery := NewServiceDiscovery(resolver)
+
+	var requestedURL, respondedURL string
+	var status statusObject
+	req := New().WithURL("srv://_http._tcp.borrowers.internal/api").
+		WithServiceDiscovery(discovery).
+		OnRequest(func(meta *Meta) {
+			requestedURL = meta.URL.String()
+		}).
+		OnResponse(func(meta *Meta, _ *ResponseMeta, _ []byte) {
+			respondedURL = meta.URL.String()
+		})
+	err := req.JSON(&status)
+	assert.Nil(err)
+	assert.Equal("ok!", status.Status)
+	assert.Equal("http://"+hostOf(ts.URL)+"/api", requestedURL)
+	assert.Equal("http://"+hostOf(ts.URL)+"/api", respondedURL)
+	assert.Equal("_http._tcp.borrowers.internal", req.URL().Host)
+
+	err = New().WithURL("srv://_http._tcp.missing.internal/api").WithServiceDiscovery(discovery).Execute()
+	assert.NotNil(err)
+}
+
+func TestServiceDiscoveryResolvedHostIsPerCall(t *testing.T) {
+	assert := assert.New(t)
+
+	resolver := &fakeSRVResolver{records: map[string][]*net.SRV{
+		"_http._tcp.borrowers.internal": {{Target: "10.0.0.1.", Port: 8080}},
+	}}
+	configured := New().WithURL("srv://_http._tcp.borrowers.internal/api")
+
+	var requestedHost string
+	var mockedHash uint32
+	req := New().WithURL("srv://_http._tcp.borrowers.internal/api").
+		WithServiceDiscovery(NewServiceDiscovery(resolver)).
+		WithMockProvider(func(req *Request) *MockedResponse {
+			requestedHost = req.URL().Host
+			mockedHash = req.Hash()
+			return &MockedResponse{Meta: ResponseMeta{StatusCode: http.StatusOK}}
+		})
+	assert.Nil(req.Execute())
+	assert.Equal("10.0.0.1:8080", requestedHost)
+	assert.Equal(configured.Hash(), mockedHash)
+
+	assert.Equal("_http._tcp.borrowers.internal", req.URL().Host)
+	assert.Equal(configured.Hash(), req.Hash())
+	assert.True(req.Equals(configured))
+}
+
+func TestServiceDiscoveryCache(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2017, 01, 01, 12, 0, 0, 0, time.UTC)
+	resolver := &fakeSRVResolver{records: map[string][]*net.SRV{
+		"borrowers": {{Target: "b1.internal.", Port: 8080}},
+	}}
+	discovery := NewServiceDiscovery(resolver).WithTTL(time.Minute)
+	discovery.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		target, err := discovery.Resolve(context.Background(), "borrowers")
+		assert.Nil(err)
+		assert.Equal("b1.internal:8080", target)
+	}
+	assert.Equal(1, resolver.lookups)
+
+	now = now.Add(time.Minute)
+	_, err := discovery.Resolve(context.Background(), "borrowers")
+	assert.Nil(err)
+	assert.Equal(2, resolver.lookups)
+}
+
+func TestServiceDiscoveryCacheRecordTTL(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2017, 01, 01, 12, 0, 0, 0, time.UTC)
+	resolver := &fakeTTLSRVResolver{
+		fakeSRVResolver: fakeSRVResolver{records: map[string][]*net.SRV{
+			"borrowers": {{Target: "b1.internal.", Port: 8080}},
+		}},
+		ttl: 5 * time.Second,
+	}
+	discovery := NewServiceDiscovery(resolver).WithTTL(time.Minute)
+	discovery.now = func() time.Time { return now }
+
+	_, err := discovery.Resolve(context.Background(), "borrowers")
+	assert.Nil(err)
+	now = now.Add(4 * time.Second)
+	_, err = discovery.Resolve(context.Background(), "borrowers")
+	assert.Nil(err)
+	assert.Equal(1, resolver.lookups)
+
+	now = now.Add(time.Second)
+	_, err = discovery.Resolve(context.Background(), "borrowers")
+	assert.Nil(err)
+	assert.Equal(2, resolver.lookups)
+}
+
+func TestServiceDiscoveryFailover(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(hostOf(ts.URL))
+	portNumber, _ := strconv.Atoi(port)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	_, deadPort, _ := net.SplitHostPort(listener.Addr().String())
+	deadPortNumber, _ := strconv.Atoi(deadPort)
+	listener.Close()
+
+	resolver := &fakeSRVResolver{records: map[string][]*net.SRV{
+		"borrowers": {
+			{Target: "127.0.0.1.", Port: uint16(portNumber), Priority: 20, Weight: 1},
+			{Target: "127.0.0.1.", Port: uint16(deadPortNumber), Priority: 10, Weight: 1},
+		},
+	}}
+
+	var hosts []string
+	var status statusObject
+	err = New().WithURL("srv://borrowers/api").
+		WithServiceDiscovery(NewServiceDiscovery(resolver)).
+		OnRequest(func(meta *Meta) {
+			hosts = append(hosts, meta.URL.Host)
+		}).
+		JSON(&status)
+	assert.Nil(err)
+	assert.Equal("ok!", status.Status)
+	assert.Equal([]string{"127.0.0.1:" + deadPort, hostOf(ts.URL)}, hosts)
+}
+
+func TestOrderSRV(t *testing.T) {
+	assert := assert.New(t)
+
+	random := rand.New(rand.NewSource(1))
+	records := []*net.SRV{
+		{Target: "backup", Priority: 20, Weight: 100},
+		{Target: "heavy", Priority: 10, Weight: 3},
+		{Target: "drained", Priority: 10, Weight: 0},
+		{Target: "light", Priority: 10, Weight: 1},
+	}
+	heavyFirst := 0
+	for i := 0; i < 1000; i++ {
+		ordered := orderSRV(records, random)
+		assert.Len(ordered, 4)
+		assert.Equal("drained", ordered[2].Target)
+		assert.Equal("backup", ordered[3].Target)
+		if ordered[0].Target == "heavy" {
+			heavyFirst++
+		}
+	}
+	assert.True(heavyFirst > 500)
+	assert.Empty(orderSRV(nil, random))
+}
+
+func TestSelectSRV(t *testing.T) {
+	assert := assert.New(t)
+
+	random := rand.New(rand.NewSource(1))
+	records := []*net.SRV{
+		{Target: "backup", Priority: 20, Weight: 100},
+		{Target: "heavy", Priority: 10, Weight: 3},
+		{Target: "light", Priority: 10, Weight: 1},
+		{Target: "drained", Priority: 10, Weight: 0},
+	}
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		counts[selectSRV(records, random).Target]++
+	}
+	assert.Equal(0, counts["backup"])
+	assert.Equal(0, counts["drained"])
+	assert.True(counts["heavy"] > 2*counts["light"])
+
+	assert.Nil(selectSRV(nil, random))
+	assert.Equal("only", selectSRV([]*net.SRV{{Target: "only"}}, random).Target)
+}
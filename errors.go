@@ -0,0 +1,160 @@
+package request
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net"
+	"syscall"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+// ErrorClass is a coarse classification of a request error.
+type ErrorClass string
+
+const (
+	// ErrorClassTimeout is a request that ran out of time.
+	ErrorClassTimeout ErrorClass = "timeout"
+	// ErrorClassDNS is a failure to resolve the request host.
+	ErrorClassDNS ErrorClass = "dns"
+	// ErrorClassConnectionRefused is a request the remote host refused to connect.
+	ErrorClassConnectionRefused ErrorClass = "connection_refused"
+	// ErrorClassTLS is a failed tls handshake or certificate verification.
+	ErrorClassTLS ErrorClass = "tls"
+	// ErrorClassCanceled is a request whose context was canceled.
+	ErrorClassCanceled ErrorClass = "canceled"
+	// ErrorClassDecode is a response body that could not be deserialized.
+	ErrorClassDecode ErrorClass = "decode"
+	// ErrorClassCircuitOpen is a request rejected by an open circuit breaker.
+	ErrorClassCircuitOpen ErrorClass = "circuit_open"
+	// ErrorClassRateLimited is a request rejected by a fail fast rate limiter.
+	ErrorClassRateLimited ErrorClass = "rate_limited"
+	// ErrorClassProblem is a response with RFC 7807 problem details.
+	ErrorClassProblem ErrorClass = "problem"
+	// ErrorClassSchema is a response body that does not match its json schema.
+	ErrorClassSchema ErrorClass = "schema"
+	// ErrorClassGraphQL is a GraphQL response with errors.
+	ErrorClassGraphQL ErrorClass = "graphql"
+	// ErrorClassRPC is a JSON-RPC error response.
+	ErrorClassRPC ErrorClass = "rpc"
+	// ErrorClassUnknown is any other error.
+	ErrorClassUnknown ErrorClass = "unknown"
+)
+
+// ClassifyError returns the class of an error returned by a request, the http client or a deserializer.
+// Errors wrapped with `exception.Wrap` are classified by the error they wrap.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+	err = unwrapException(err)
+
+	if errors.Is(err, ErrRateLimited) {
+		return ErrorClassRateLimited
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return ErrorClassCircuitOpen
+	}
+
+	var problem *ProblemDetails
+	if errors.As(err, &problem) {
+		return ErrorClassProblem
+	}
+
+	var graphQLErrs GraphQLErrors
+	if errors.As(err, &graphQLErrs) {
+		return ErrorClassGraphQL
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return ErrorClassRPC
+	}
+
+	var schemaErr *SchemaValidationError
+	if errors.As(err, &schemaErr) {
+		return ErrorClassSchema
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassCanceled
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorClassDNS
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorClassConnectionRefused
+	}
+
+	if isTLSError(err) {
+		return ErrorClassTLS
+	}
+
+	if isDecodeError(err) {
+		return ErrorClassDecode
+	}
+
+	return ErrorClassUnknown
+}
+
+// unwrapException returns the error an exception wraps, as exceptions do not implement `Unwrap`.
+func unwrapException(err error) error {
+	for {
+		ex, isException := err.(*exception.Ex)
+		if !isException || ex.Inner() == nil {
+			return err
+		}
+		err = ex.Inner()
+	}
+}
+
+func isTLSError(err error) bool {
+	var recordHeaderErr tls.RecordHeaderError
+	var verificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+	var systemRootsErr x509.SystemRootsError
+	var constraintErr x509.ConstraintViolationError
+	var insecureAlgorithmErr x509.InsecureAlgorithmError
+	var pinErr *PublicKeyPinError
+	if errors.As(err, &recordHeaderErr) ||
+		errors.As(err, &verificationErr) ||
+		errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &certificateInvalidErr) ||
+		errors.As(err, &systemRootsErr) ||
+		errors.As(err, &constraintErr) ||
+		errors.As(err, &insecureAlgorithmErr) ||
+		errors.As(err, &pinErr) {
+		return true
+	}
+
+	// alerts sent by the remote side of a tls handshake are an unexported type in a `remote error` op.
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "remote error"
+}
+
+func isDecodeError(err error) bool {
+	var jsonSyntaxErr *json.SyntaxError
+	var jsonTypeErr *json.UnmarshalTypeError
+	var xmlSyntaxErr *xml.SyntaxError
+	return errors.As(err, &jsonSyntaxErr) ||
+		errors.As(err, &jsonTypeErr) ||
+		errors.As(err, &xmlSyntaxErr)
+}
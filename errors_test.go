@@ -0,0 +1,127 @@
+package request
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+	exception "github.com/blendlabs/go-exception"
+)
+
+func TestClassifyError(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(ErrorClass(""), ClassifyError(nil))
+	assert.Equal(ErrorClassCanceled, ClassifyError(context.Canceled))
+	assert.Equal(ErrorClassTimeout, ClassifyError(context.DeadlineExceeded))
+	assert.Equal(ErrorClassDNS, ClassifyError(&net.DNSError{Err: "no such host", Name: "foo.invalid"}))
+	assert.Equal(ErrorClassDecode, ClassifyError(deserializeJSONRaw([]byte("{"))))
+	assert.Equal(ErrorClassUnknown, ClassifyError(exception.New("something else")))
+	assert.Equal(ErrorClassCanceled, ClassifyError(exception.Wrap(context.Canceled)))
+	assert.Equal(ErrorClassDecode, ClassifyError(exception.Wrap(deserializeJSONRaw([]byte("{")))))
+	assert.Equal(ErrorClassCircuitOpen, ClassifyError(wrap(ErrCircuitOpen)))
+}
+
+func deserializeJSONRaw(body []byte) error {
+	var value map[string]interface{}
+	return json.Unmarshal(body, &value)
+}
+
+func TestOnErrorConnectionRefused(t *testing.T) {
+	assert := assert.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	var hookErr error
+	var hookMeta *Meta
+	_, err = New().WithURL("http://" + addr + "/").OnError(func(req *Meta, err error) {
+		hookMeta = req
+		hookErr = err
+	}).String()
+	assert.NotNil(err)
+	assert.NotNil(hookMeta)
+	assert.Equal(ErrorClassConnectionRefused, ClassifyError(hookErr))
+	assert.Equal(ErrorClassConnectionRefused, ClassifyError(err))
+}
+
+func TestOnErrorTLS(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var hookErr error
+	_, err := New().WithURL(ts.URL).OnError(func(_ *Meta, err error) {
+		hookErr = err
+	}).String()
+	assert.NotNil(err)
+	assert.Equal(ErrorClassTLS, ClassifyError(hookErr))
+}
+
+func TestOnErrorTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(okMeta(), statusOkObject(), func(r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	})
+	defer ts.Close()
+
+	var hookErr error
+	_, err := New().WithURL(ts.URL).WithTimeout(10 * time.Millisecond).OnError(func(_ *Meta, err error) {
+		hookErr = err
+	}).String()
+	assert.NotNil(err)
+	assert.Equal(ErrorClassTimeout, ClassifyError(hookErr))
+}
+
+func TestOnErrorDecode(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	})
+	defer ts.Close()
+
+	var hookErr error
+	var object statusObject
+	err := New().WithURL(ts.URL).OnError(func(_ *Meta, err error) {
+		hookErr = err
+	}).JSON(&object)
+	assert.NotNil(err)
+	assert.Equal(ErrorClassDecode, ClassifyError(hookErr))
+	assert.Equal(ErrorClassDecode, ClassifyError(err))
+}
+
+func TestClassifyErrorTLSTypes(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(ErrorClassUnknown, ClassifyError(fmt.Errorf("config: tls: invalid option")))
+	assert.Equal(ErrorClassTLS, ClassifyError(&url.Error{Op: "Get", URL: "https://foo", Err: &PublicKeyPinError{}}))
+	assert.Equal(ErrorClassTLS, ClassifyError(x509.UnknownAuthorityError{}))
+}
+
+func TestOnErrorRequestBuild(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, req := range []*Request{
+		New().WithURL("http://localhost/").WithPathTemplate("/users/{id}").WithPathParam("id", ".."),
+		New().AsPost().WithURL("http://localhost/").WithPostData("foo", "bar").WithPostBody([]byte("baz")),
+		New().WithURL("http://localhost/").WithRootCAsPEM([]byte("not pem")),
+	} {
+		var hookErr error
+		err := req.OnError(func(_ *Meta, err error) { hookErr = err }).Execute()
+		assert.NotNil(err)
+		assert.NotNil(hookErr)
+	}
+}
@@ -1,8 +1,10 @@
 package request
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"time"
 
 	logger "github.com/blendlabs/go-logger"
 )
@@ -12,6 +14,12 @@ const (
 	Event logger.EventFlag = "request"
 	// EventResponse is a diagnostics agent event flag.
 	EventResponse logger.EventFlag = "request.response"
+	// EventError is a diagnostics agent event flag.
+	EventError logger.EventFlag = "request.error"
+	// EventCircuitBreaker is a diagnostics agent event flag.
+	EventCircuitBreaker logger.EventFlag = "request.circuit_breaker"
+	// EventSchemaViolation is a diagnostics agent event flag.
+	EventSchemaViolation logger.EventFlag = "request.schema_violation"
 )
 
 // NewOutgoingListener creates a new logger handler for `EventFlagOutgoingResponse` events.
@@ -61,3 +69,156 @@ func WriteOutgoingRequestResponse(writer *logger.Writer, ts logger.TimeSource, r
 	buffer.Write(body)
 	writer.WriteWithTimeSource(ts, buffer.Bytes())
 }
+
+// NewOutgoingErrorListener creates a new logger handler for `EventError` events.
+func NewOutgoingErrorListener(handler func(writer *logger.Writer, ts logger.TimeSource, req *Meta, err error, class ErrorClass)) logger.EventListener {
+	return func(writer *logger.Writer, ts logger.TimeSource, eventFlag logger.EventFlag, state ...interface{}) {
+		handler(writer, ts, state[0].(*Meta), state[1].(error), state[2].(ErrorClass))
+	}
+}
+
+// WriteOutgoingRequestError is a helper method to write outgoing request error events to a logger writer.
+func WriteOutgoingRequestError(writer *logger.Writer, ts logger.TimeSource, req *Meta, err error, class ErrorClass) {
+	buffer := writer.GetBuffer()
+	defer writer.PutBuffer(buffer)
+	buffer.WriteString(writer.Colorize(string(EventError), logger.ColorRed))
+	buffer.WriteRune(logger.RuneSpace)
+	buffer.WriteString(fmt.Sprintf("%s %s %s %v", class, req.Verb, req.URL.String(), err))
+	writer.WriteWithTimeSource(ts, buffer.Bytes())
+}
+
+// NewCircuitBreakerListener creates a new logger handler for `EventCircuitBreaker` events.
+func NewCircuitBreakerListener(handler func(writer *logger.Writer, ts logger.TimeSource, key string, from, to CircuitState)) logger.EventListener {
+	return func(writer *logger.Writer, ts logger.TimeSource, eventFlag logger.EventFlag, state ...interface{}) {
+		handler(writer, ts, state[0].(string), state[1].(CircuitState), state[2].(CircuitState))
+	}
+}
+
+// WriteCircuitBreakerStateChange is a helper method to write circuit breaker state changes to a logger writer.
+func WriteCircuitBreakerStateChange(writer *logger.Writer, ts logger.TimeSource, key string, from, to CircuitState) {
+	buffer := writer.GetBuffer()
+	defer writer.PutBuffer(buffer)
+	buffer.WriteString(writer.Colorize(string(EventCircuitBreaker), logger.ColorYellow))
+	buffer.WriteRune(logger.RuneSpace)
+	buffer.WriteString(fmt.Sprintf("%s %s -> %s", key, from, to))
+	writer.WriteWithTimeSource(ts, buffer.Bytes())
+}
+
+// NewSchemaViolationListener creates a new logger handler for `EventSchemaViolation` events.
+func NewSchemaViolationListener(handler func(writer *logger.Writer, ts logger.TimeSource, req *Meta, err *SchemaValidationError)) logger.EventListener {
+	return func(writer *logger.Writer, ts logger.TimeSource, eventFlag logger.EventFlag, state ...interface{}) {
+		handler(writer, ts, state[0].(*Meta), state[1].(*SchemaValidationError))
+	}
+}
+
+// WriteSchemaViolation is a helper method to write response schema violations to a logger writer.
+func WriteSchemaViolation(writer *logger.Writer, ts logger.TimeSource, req *Meta, err *SchemaValidationError) {
+	buffer := writer.GetBuffer()
+	defer writer.PutBuffer(buffer)
+	buffer.WriteString(writer.Colorize(string(EventSchemaViolation), logger.ColorYellow))
+	buffer.WriteRune(logger.RuneSpace)
+	buffer.WriteString(fmt.Sprintf("%s %s", req.Verb, req.URL.String()))
+	for _, violation := range err.Violations {
+		buffer.WriteRune(logger.RuneNewline)
+		buffer.WriteString(violation.String())
+	}
+	writer.WriteWithTimeSource(ts, buffer.Bytes())
+}
+
+// WriteOutgoingRequestJSON is a helper method to write outgoing request events to a logger writer as a json object.
+func WriteOutgoingRequestJSON(writer *logger.Writer, ts logger.TimeSource, req *Meta) {
+	writeJSONEvent(writer, ts, NewJSONEvent(Event, req, nil))
+}
+
+// WriteOutgoingRequestResponseJSON is a helper method to write outgoing request response events to a logger writer as a json object.
+func WriteOutgoingRequestResponseJSON(writer *logger.Writer, ts logger.TimeSource, req *Meta, res *ResponseMeta, body []byte) {
+	writeJSONEvent(writer, ts, NewJSONEvent(EventResponse, req, res))
+}
+
+// WriteOutgoingRequestJSONWithBody returns a writer for outgoing request events that includes
+// the request body, truncated to `maxBodyLength` bytes if `maxBodyLength` is positive.
+func WriteOutgoingRequestJSONWithBody(maxBodyLength int) func(writer *logger.Writer, ts logger.TimeSource, req *Meta) {
+	return func(writer *logger.Writer, ts logger.TimeSource, req *Meta) {
+		event := NewJSONEvent(Event, req, nil)
+		event.RequestBody, event.RequestBodyTruncated = truncateBody(req.Body, maxBodyLength)
+		writeJSONEvent(writer, ts, event)
+	}
+}
+
+// WriteOutgoingRequestResponseJSONWithBody returns a writer for outgoing request response events that includes
+// the response body, truncated to `maxBodyLength` bytes if `maxBodyLength` is positive.
+func WriteOutgoingRequestResponseJSONWithBody(maxBodyLength int) func(writer *logger.Writer, ts logger.TimeSource, req *Meta, res *ResponseMeta, body []byte) {
+	return func(writer *logger.Writer, ts logger.TimeSource, req *Meta, res *ResponseMeta, body []byte) {
+		event := NewJSONEvent(EventResponse, req, res)
+		event.ResponseBody, event.ResponseBodyTruncated = truncateBody(body, maxBodyLength)
+		writeJSONEvent(writer, ts, event)
+	}
+}
+
+// WriteOutgoingRequestErrorJSON is a helper method to write outgoing request error events to a logger writer as a json object.
+func WriteOutgoingRequestErrorJSON(writer *logger.Writer, ts logger.TimeSource, req *Meta, err error, class ErrorClass) {
+	event := NewJSONEvent(EventError, req, nil)
+	event.Error = err.Error()
+	event.ErrorClass = string(class)
+	writeJSONEvent(writer, ts, event)
+}
+
+// NewJSONEvent returns a new structured event for a request and an optional response.
+func NewJSONEvent(flag logger.EventFlag, req *Meta, res *ResponseMeta) *JSONEvent {
+	event := &JSONEvent{
+		Event:        string(flag),
+		Label:        req.Label,
+		Attempt:      req.Attempt,
+		Verb:         req.Verb,
+		PathTemplate: req.PathTemplate,
+	}
+	if req.URL != nil {
+		event.URL = req.URL.String()
+	}
+	if res != nil {
+		event.StatusCode = res.StatusCode
+		event.ContentLength = res.ContentLength
+		if !req.StartTime.IsZero() && !res.CompleteTime.IsZero() {
+			event.Elapsed = durationMillis(res.CompleteTime.Sub(req.StartTime))
+		}
+	}
+	return event
+}
+
+// JSONEvent is the structured form of a request event.
+type JSONEvent struct {
+	Timestamp             time.Time `json:"timestamp"`
+	Event                 string    `json:"event"`
+	Label                 string    `json:"label,omitempty"`
+	Attempt               int       `json:"attempt,omitempty"`
+	Verb                  string    `json:"verb"`
+	URL                   string    `json:"url"`
+	PathTemplate          string    `json:"path_template,omitempty"`
+	StatusCode            int       `json:"status,omitempty"`
+	Elapsed               float64   `json:"elapsed_ms,omitempty"`
+	ContentLength         int64     `json:"content_length,omitempty"`
+	Error                 string    `json:"error,omitempty"`
+	ErrorClass            string    `json:"error_class,omitempty"`
+	RequestBody           string    `json:"request_body,omitempty"`
+	RequestBodyTruncated  bool      `json:"request_body_truncated,omitempty"`
+	ResponseBody          string    `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool      `json:"response_body_truncated,omitempty"`
+}
+
+// writeJSONEvent writes an event as a single line of json to the writer output.
+// Remarks: it does not use `WriteWithTimeSource`, which would prefix the line with a timestamp and label.
+func writeJSONEvent(writer *logger.Writer, ts logger.TimeSource, event *JSONEvent) {
+	event.Timestamp = ts.UTCNow()
+	contents, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	writer.Output().Write(append(contents, '\n'))
+}
+
+func truncateBody(body []byte, maxLength int) (string, bool) {
+	if maxLength > 0 && len(body) > maxLength {
+		return string(body[:maxLength]), true
+	}
+	return string(body), false
+}
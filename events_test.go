@@ -0,0 +1,110 @@
+package request
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+	logger "github.com/blendlabs/go-logger"
+)
+
+func TestNewJSONEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	req := New().AsPost().WithURL("http://localhost/api?foo=bar").WithLabel("borrowers").Meta()
+	req.StartTime = time.Date(2017, 01, 01, 12, 0, 0, 0, time.UTC)
+	req.Attempt = 2
+	res := &ResponseMeta{StatusCode: 200, ContentLength: 10, CompleteTime: req.StartTime.Add(1500 * time.Microsecond)}
+
+	event := NewJSONEvent(EventResponse, req, res)
+	contents, err := json.Marshal(event)
+	assert.Nil(err)
+
+	var fields map[string]interface{}
+	assert.Nil(json.Unmarshal(contents, &fields))
+	assert.Equal(string(EventResponse), fields["event"])
+	assert.Equal("borrowers", fields["label"])
+	assert.Equal("POST", fields["verb"])
+	assert.Equal("http://localhost/api?foo=bar", fields["url"])
+	assert.Equal(float64(200), fields["status"])
+	assert.Equal(float64(10), fields["content_length"])
+	assert.Equal(float64(2), fields["attempt"])
+	assert.Equal(1.5, fields["elapsed_ms"])
+	_, hasBody := fields["response_body"]
+	assert.False(hasBody)
+}
+
+type fixedTime time.Time
+
+func (ft fixedTime) UTCNow() time.Time {
+	return time.Time(ft)
+}
+
+func TestJSONEventListeners(t *testing.T) {
+	assert := assert.New(t)
+
+	output := bytes.NewBuffer(nil)
+	writer := logger.NewWriter(output)
+	ts := fixedTime(time.Date(2017, 01, 01, 12, 0, 0, 0, time.UTC))
+
+	req := New().AsPost().WithURL("http://localhost/api").WithPostBody([]byte("request body")).Meta()
+	res := &ResponseMeta{StatusCode: 200}
+
+	NewOutgoingListener(WriteOutgoingRequestJSON)(writer, ts, Event, req)
+	NewOutgoingListener(WriteOutgoingRequestJSONWithBody(7))(writer, ts, Event, req)
+	NewOutgoingResponseListener(WriteOutgoingRequestResponseJSON)(writer, ts, EventResponse, req, res, []byte("ok"))
+	NewOutgoingResponseListener(WriteOutgoingRequestResponseJSONWithBody(0))(writer, ts, EventResponse, req, res, []byte("ok"))
+	NewOutgoingErrorListener(WriteOutgoingRequestErrorJSON)(writer, ts, EventError, req, errors.New("refused"), ErrorClassConnectionRefused)
+
+	var events []JSONEvent
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		var event JSONEvent
+		assert.Nil(json.Unmarshal(scanner.Bytes(), &event), scanner.Text())
+		events = append(events, event)
+	}
+	assert.Len(events, 5)
+	for _, event := range events {
+		assert.Equal(time.Time(ts), event.Timestamp)
+		assert.Equal("http://localhost/api", event.URL)
+	}
+	assert.Equal(string(Event), events[0].Event)
+	assert.Equal("", events[0].RequestBody)
+	assert.Equal("request", events[1].RequestBody)
+	assert.True(events[1].RequestBodyTruncated)
+	assert.Equal(200, events[2].StatusCode)
+	assert.Equal("ok", events[3].ResponseBody)
+	assert.Equal("refused", events[4].Error)
+	assert.Equal(string(ErrorClassConnectionRefused), events[4].ErrorClass)
+}
+
+func TestTruncateBody(t *testing.T) {
+	assert := assert.New(t)
+
+	body, truncated := truncateBody([]byte("hello world"), 5)
+	assert.Equal("hello", body)
+	assert.True(truncated)
+
+	body, truncated = truncateBody([]byte("hello world"), 0)
+	assert.Equal("hello world", body)
+	assert.False(truncated)
+}
+
+func TestRequestAttempt(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEchoEndpoint(okMeta())
+	defer ts.Close()
+
+	var attempts []int
+	req := New().WithURL(ts.URL).OnRequest(func(meta *Meta) {
+		attempts = append(attempts, meta.Attempt)
+	})
+	assert.Nil(req.Execute())
+	assert.Nil(req.Execute())
+	assert.Equal([]int{1, 2}, attempts)
+}
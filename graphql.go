@@ -0,0 +1,164 @@
+package request
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+const (
+	// GraphQLPersistedQueryNotFound is the error a server returns for an unknown persisted query hash.
+	GraphQLPersistedQueryNotFound = "PersistedQueryNotFound"
+)
+
+// GraphQLOperation is the body of a GraphQL request.
+type GraphQLOperation struct {
+	Query         string                 `json:"query,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     interface{}            `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLLocation is a position in a GraphQL query.
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError is a single entry of the `errors` array of a GraphQL response.
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Locations  []GraphQLLocation      `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// PathString returns the path of the error joined with dots, e.g. `borrower.documents.0.name`.
+func (ge GraphQLError) PathString() string {
+	segments := make([]string, 0, len(ge.Path))
+	for _, segment := range ge.Path {
+		segments = append(segments, fmt.Sprint(segment))
+	}
+	return strings.Join(segments, ".")
+}
+
+// GraphQLErrors are the errors returned by a GraphQL response.
+type GraphQLErrors []GraphQLError
+
+// Error implements error.
+func (ge GraphQLErrors) Error() string {
+	messages := make([]string, 0, len(ge))
+	for _, err := range ge {
+		if path := err.PathString(); !isEmpty(path) {
+			messages = append(messages, path+": "+err.Message)
+		} else {
+			messages = append(messages, err.Message)
+		}
+	}
+	return "request: graphql: " + strings.Join(messages, "; ")
+}
+
+func (ge GraphQLErrors) isTyped() {}
+
+func (ge GraphQLErrors) persistedQueryNotFound() bool {
+	for _, err := range ge {
+		if err.Message == GraphQLPersistedQueryNotFound || err.Extensions["code"] == "PERSISTED_QUERY_NOT_FOUND" {
+			return true
+		}
+	}
+	return false
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GraphQLErrors   `json:"errors"`
+}
+
+// WithGraphQLOperationName sets the operation to run when a GraphQL query has more than one.
+func (hr *Request) WithGraphQLOperationName(operationName string) *Request {
+	hr.graphQLOperationName = operationName
+	return hr
+}
+
+// WithGraphQLPersistedQuery sends the sha256 hash of GraphQL queries instead of the query text,
+// as an automatic persisted query. If the server does not know the hash the request is sent again with the full query.
+func (hr *Request) WithGraphQLPersistedQuery() *Request {
+	hr.graphQLPersistedQuery = true
+	return hr
+}
+
+// GraphQL posts a GraphQL query with its variables and unmarshals the `data` of the response to an object.
+// If the response has `errors` they are returned as `GraphQLErrors`; any partial data is still unmarshaled.
+func (hr *Request) GraphQL(query string, variables interface{}, destination interface{}) error {
+	_, err := hr.GraphQLWithMeta(query, variables, destination)
+	return err
+}
+
+// GraphQLWithMeta posts a GraphQL query and unmarshals the `data` of the response to an object with metadata.
+func (hr *Request) GraphQLWithMeta(query string, variables interface{}, destination interface{}) (*ResponseMeta, error) {
+	operation := &GraphQLOperation{
+		Query:         query,
+		OperationName: hr.graphQLOperationName,
+		Variables:     variables,
+	}
+	if hr.graphQLPersistedQuery {
+		hash := sha256.Sum256([]byte(query))
+		operation.Query = ""
+		operation.Extensions = map[string]interface{}{
+			"persistedQuery": map[string]interface{}{
+				"version":    1,
+				"sha256Hash": hex.EncodeToString(hash[:]),
+			},
+		}
+	}
+
+	meta, err := hr.graphQL(operation, destination, hr.graphQLPersistedQuery)
+	if graphQLErrs, isGraphQLErrs := err.(GraphQLErrors); isGraphQLErrs && hr.graphQLPersistedQuery && graphQLErrs.persistedQueryNotFound() {
+		operation.Query = query
+		return hr.graphQL(operation, destination, false)
+	}
+	return meta, err
+}
+
+// graphQL posts the operation from a copy of the request, so the request itself can be sent again.
+// If `retryingMiss` is set a persisted query miss is returned without being logged, as it will be retried.
+func (hr *Request) graphQL(operation *GraphQLOperation, destination interface{}, retryingMiss bool) (*ResponseMeta, error) {
+	req := hr.clone().AsPost().WithPostBodyAsJSON(operation)
+	var body []byte
+	meta, err := req.deserialize(func(contents []byte) error {
+		body = contents
+		return nil
+	})
+	if err != nil {
+		return meta, err
+	}
+
+	var response graphQLResponse
+	err = json.Unmarshal(body, &response)
+	failedStatus := meta.StatusCode < http.StatusOK || meta.StatusCode >= http.StatusMultipleChoices
+	if failedStatus && (err != nil || len(response.Errors) == 0) {
+		// an error status without graphql errors is an http error, e.g. from a proxy in front of the server.
+		err = exception.Newf("request: graphql: unexpected status code %d", meta.StatusCode)
+		req.logError(err, ErrorClassUnknown)
+		return meta, err
+	}
+	if err == nil && len(response.Data) > 0 && string(response.Data) != "null" && destination != nil {
+		err = json.Unmarshal(response.Data, destination)
+	}
+	if err != nil {
+		req.logError(err, ErrorClassDecode)
+		return meta, wrap(err)
+	}
+	if len(response.Errors) > 0 {
+		if !(retryingMiss && response.Errors.persistedQueryNotFound()) {
+			req.logError(response.Errors, ErrorClassGraphQL)
+		}
+		return meta, response.Errors
+	}
+	return meta, nil
+}
@@ -0,0 +1,154 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func graphQLEndpoint(handler func(operation GraphQLOperation) interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var operation GraphQLOperation
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &operation)
+		writeJSON(w, okMeta(), handler(operation))
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	assert := assert.New(t)
+
+	var received GraphQLOperation
+	var contentType, verb string
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		verb = r.Method
+		graphQLEndpoint(func(operation GraphQLOperation) interface{} {
+			received = operation
+			return map[string]interface{}{"data": map[string]interface{}{"borrower": map[string]interface{}{"name": "foo"}}}
+		})(w, r)
+	})
+	defer ts.Close()
+
+	var data struct {
+		Borrower struct {
+			Name string `json:"name"`
+		} `json:"borrower"`
+	}
+	err := New().WithURL(ts.URL).
+		WithGraphQLOperationName("GetBorrower").
+		GraphQL("query GetBorrower($id: ID!) { borrower(id: $id) { name } }", map[string]interface{}{"id": "1"}, &data)
+	assert.Nil(err)
+	assert.Equal("foo", data.Borrower.Name)
+	assert.Equal("POST", verb)
+	assert.Equal("application/json", contentType)
+	assert.Equal("GetBorrower", received.OperationName)
+	assert.Equal("1", received.Variables.(map[string]interface{})["id"])
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(graphQLEndpoint(func(operation GraphQLOperation) interface{} {
+		return map[string]interface{}{
+			"data": map[string]interface{}{"borrower": map[string]interface{}{"name": "foo", "documents": nil}},
+			"errors": []interface{}{
+				map[string]interface{}{
+					"message":    "not authorized",
+					"locations":  []interface{}{map[string]interface{}{"line": 1, "column": 20}},
+					"path":       []interface{}{"borrower", "documents", 0},
+					"extensions": map[string]interface{}{"code": "FORBIDDEN"},
+				},
+			},
+		}
+	}))
+	defer ts.Close()
+
+	var data struct {
+		Borrower struct {
+			Name string `json:"name"`
+		} `json:"borrower"`
+	}
+	var class ErrorClass
+	err := New().WithURL(ts.URL).OnError(func(req *Meta, err error) {
+		class = ClassifyError(err)
+	}).GraphQL("{ borrower { name documents { id } } }", nil, &data)
+	assert.NotNil(err)
+	assert.Equal("foo", data.Borrower.Name)
+	assert.Equal(ErrorClassGraphQL, class)
+
+	var graphQLErrs GraphQLErrors
+	assert.True(errors.As(err, &graphQLErrs))
+	assert.Len(graphQLErrs, 1)
+	assert.Equal("not authorized", graphQLErrs[0].Message)
+	assert.Equal("borrower.documents.0", graphQLErrs[0].PathString())
+	assert.Equal(20, graphQLErrs[0].Locations[0].Column)
+	assert.Equal("FORBIDDEN", graphQLErrs[0].Extensions["code"])
+}
+
+func TestGraphQLPersistedQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	var operations []GraphQLOperation
+	ts := getMockServer(graphQLEndpoint(func(operation GraphQLOperation) interface{} {
+		operations = append(operations, operation)
+		if len(operation.Query) == 0 {
+			return map[string]interface{}{"errors": []interface{}{map[string]interface{}{"message": GraphQLPersistedQueryNotFound}}}
+		}
+		return map[string]interface{}{"data": map[string]interface{}{"ok": true}}
+	}))
+	defer ts.Close()
+
+	var data struct {
+		OK bool `json:"ok"`
+	}
+	var errs []error
+	req := New().WithURL(ts.URL).WithGraphQLPersistedQuery().OnError(func(_ *Meta, err error) {
+		errs = append(errs, err)
+	})
+	err := req.GraphQL("{ ok }", nil, &data)
+	assert.Nil(err)
+	assert.True(data.OK)
+	assert.Empty(errs)
+	assert.Equal("GET", req.Verb)
+	assert.Empty(req.PostBody())
+	assert.Len(operations, 2)
+	assert.Empty(operations[0].Query)
+	persistedQuery := operations[0].Extensions["persistedQuery"].(map[string]interface{})
+	assert.Equal("991699baaf03398aa334a75f133c0f259a70906e7405484c2c5218cf9fb61def", persistedQuery["sha256Hash"])
+	assert.Equal("{ ok }", operations[1].Query)
+	assert.Equal(persistedQuery["sha256Hash"], operations[1].Extensions["persistedQuery"].(map[string]interface{})["sha256Hash"])
+}
+
+func TestGraphQLStatusError(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(errorMeta(), map[string]interface{}{"data": nil}, nil)
+	defer ts.Close()
+
+	err := New().WithURL(ts.URL).GraphQL("{ ok }", nil, nil)
+	assert.NotNil(err)
+}
+
+func TestGraphQLStatusErrorWithoutJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html>Bad Gateway</html>"))
+	})
+	defer ts.Close()
+
+	var classes []ErrorClass
+	err := New().WithURL(ts.URL).
+		OnError(func(_ *Meta, err error) { classes = append(classes, ClassifyError(err)) }).
+		GraphQL("{ ok }", nil, nil)
+	assert.NotNil(err)
+	assert.True(strings.Contains(err.Error(), "unexpected status code 502"))
+	assert.Equal([]ErrorClass{ErrorClassUnknown}, classes)
+}
@@ -0,0 +1,298 @@
+package request
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+const (
+	// HARVersion is the version of the HAR format written by `HARRecorder`.
+	HARVersion = "1.2"
+	// HARCreatorName is the creator name written to HAR logs.
+	HARCreatorName = "go-request"
+)
+
+var (
+	// HARCreatorVersion is the creator version written to HAR logs: the version of this library
+	// in the build of the running binary, or `devel` if it is not known.
+	HARCreatorVersion = libraryVersion()
+)
+
+// NewHARRecorder returns a new HAR recorder.
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{}
+}
+
+// HARRecorder collects request and response pairs and exports them as a HAR 1.2 log.
+// Its `Add` method is a `ResponseHandler`, so it can be passed to `OnResponse` directly.
+type HARRecorder struct {
+	lock    sync.Mutex
+	entries []HAREntry
+}
+
+// Add records a request / response pair.
+func (hr *HARRecorder) Add(req *Meta, res *ResponseMeta, body []byte) {
+	entry := NewHAREntry(req, res, body)
+	hr.lock.Lock()
+	hr.entries = append(hr.entries, entry)
+	hr.lock.Unlock()
+}
+
+// Len returns the number of recorded entries.
+func (hr *HARRecorder) Len() int {
+	hr.lock.Lock()
+	defer hr.lock.Unlock()
+	return len(hr.entries)
+}
+
+// HAR returns the recorded entries as a HAR document.
+func (hr *HARRecorder) HAR() *HAR {
+	hr.lock.Lock()
+	defer hr.lock.Unlock()
+	entries := make([]HAREntry, len(hr.entries))
+	copy(entries, hr.entries)
+	return &HAR{
+		Log: HARLog{
+			Version: HARVersion,
+			Creator: HARCreator{Name: HARCreatorName, Version: HARCreatorVersion},
+			Entries: entries,
+		},
+	}
+}
+
+// WriteTo writes the recorded entries as HAR json to a writer.
+func (hr *HARRecorder) WriteTo(writer io.Writer) (int64, error) {
+	contents, err := json.MarshalIndent(hr.HAR(), "", "  ")
+	if err != nil {
+		return 0, exception.Wrap(err)
+	}
+	written, err := writer.Write(contents)
+	return int64(written), exception.Wrap(err)
+}
+
+// WriteFile writes the recorded entries to a `.har` file at the given path.
+func (hr *HARRecorder) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return exception.Wrap(err)
+	}
+	defer f.Close()
+	_, err = hr.WriteTo(f)
+	return err
+}
+
+// NewHAREntry returns a HAR entry for a request / response pair.
+func NewHAREntry(req *Meta, res *ResponseMeta, body []byte) HAREntry {
+	entry := HAREntry{
+		Cache: struct{}{},
+	}
+	if req != nil {
+		entry.StartedDateTime = req.StartTime
+		entry.Request = newHARRequest(req)
+	}
+	if res != nil {
+		entry.Response = newHARResponse(res, body)
+		if req != nil && !req.StartTime.IsZero() && !res.CompleteTime.IsZero() {
+			entry.Time = durationMillis(res.CompleteTime.Sub(req.StartTime))
+		}
+	}
+	entry.Timings = HARTimings{Send: 0, Wait: entry.Time, Receive: 0}
+	return entry
+}
+
+// HAR is the root of a HAR 1.2 document.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the `log` member of a HAR document.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator identifies the application that wrote a HAR document.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a single request / response pair in a HAR document.
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+}
+
+// HARRequest is the request half of a HAR entry.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse is the response half of a HAR entry.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARNameValue is a name / value pair used for headers, cookies and query strings.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is the posted body of a HAR request.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent is the body of a HAR response.
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings are the timings of a HAR entry in milliseconds.
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func newHARRequest(req *Meta) HARRequest {
+	harReq := HARRequest{
+		Method:      req.Verb,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARNameValue{},
+		Headers:     harNameValues(req.Headers),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(req.Body),
+	}
+	if req.URL != nil {
+		harReq.URL = req.URL.String()
+		harReq.QueryString = harNameValues(req.URL.Query())
+	}
+	cookies := (&http.Request{Header: req.Headers}).Cookies()
+	for _, cookie := range req.Cookies {
+		if !hasCookie(cookies, cookie) {
+			cookies = append(cookies, cookie)
+		}
+	}
+	harReq.Cookies = harCookies(cookies)
+	if len(req.Body) > 0 {
+		harReq.PostData = &HARPostData{
+			MimeType: req.Headers.Get("Content-Type"),
+			Text:     string(req.Body),
+		}
+	}
+	return harReq
+}
+
+func newHARResponse(res *ResponseMeta, body []byte) HARResponse {
+	harRes := HARResponse{
+		Status:      res.StatusCode,
+		StatusText:  http.StatusText(res.StatusCode),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     harCookies((&http.Response{Header: res.Headers}).Cookies()),
+		Headers:     harNameValues(res.Headers),
+		RedirectURL: res.Headers.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    res.ContentLength,
+		Content: HARContent{
+			Size:     int64(len(body)),
+			MimeType: res.ContentType,
+		},
+	}
+	if utf8.Valid(body) {
+		harRes.Content.Text = string(body)
+	} else {
+		harRes.Content.Text = base64.StdEncoding.EncodeToString(body)
+		harRes.Content.Encoding = "base64"
+	}
+	return harRes
+}
+
+func harNameValues(values map[string][]string) []HARNameValue {
+	output := []HARNameValue{}
+	for _, key := range sortedKeys(values) {
+		for _, value := range values[key] {
+			output = append(output, HARNameValue{Name: key, Value: value})
+		}
+	}
+	return output
+}
+
+func harCookies(cookies []*http.Cookie) []HARNameValue {
+	output := []HARNameValue{}
+	for _, cookie := range cookies {
+		output = append(output, HARNameValue{Name: cookie.Name, Value: strings.TrimSpace(cookie.Value)})
+	}
+	return output
+}
+
+func hasCookie(cookies []*http.Cookie, cookie *http.Cookie) bool {
+	for _, existing := range cookies {
+		if existing.Name == cookie.Name && existing.Value == cookie.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// libraryVersion returns the version of this library from the build info of the running binary.
+func libraryVersion() string {
+	path := reflect.TypeOf(HARRecorder{}).PkgPath()
+	if info, hasInfo := debug.ReadBuildInfo(); hasInfo {
+		modules := append([]*debug.Module{&info.Main}, info.Deps...)
+		for _, module := range modules {
+			if module.Path != path {
+				continue
+			}
+			if module.Replace != nil {
+				module = module.Replace
+			}
+			if !isEmpty(module.Version) && module.Version != "(devel)" {
+				return module.Version
+			}
+		}
+	}
+	return "devel"
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,57 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func TestHARRecorder(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEchoEndpoint(okMeta())
+	defer ts.Close()
+
+	recorder := NewHARRecorder()
+	_, err := New().AsPost().WithURL(ts.URL + "/echo?foo=bar").
+		WithPostBody([]byte(`{"status":"ok!"}`)).
+		WithContentType("application/json").
+		WithCookie(&http.Cookie{Name: "session", Value: "abc"}).
+		OnResponse(recorder.Add).
+		String()
+	assert.Nil(err)
+	assert.Equal(1, recorder.Len())
+
+	buffer := bytes.NewBuffer(nil)
+	_, err = recorder.WriteTo(buffer)
+	assert.Nil(err)
+
+	var har HAR
+	assert.Nil(json.Unmarshal(buffer.Bytes(), &har))
+	assert.Equal(HARVersion, har.Log.Version)
+	assert.Equal(HARCreatorVersion, har.Log.Creator.Version)
+	assert.Len(har.Log.Entries, 1)
+
+	entry := har.Log.Entries[0]
+	assert.Equal("POST", entry.Request.Method)
+	assert.Equal(ts.URL+"/echo?foo=bar", entry.Request.URL)
+	assert.Equal([]HARNameValue{{Name: "foo", Value: "bar"}}, entry.Request.QueryString)
+	assert.Equal([]HARNameValue{{Name: "session", Value: "abc"}}, entry.Request.Cookies)
+	assert.NotNil(entry.Request.PostData)
+	assert.Equal("application/json", entry.Request.PostData.MimeType)
+	assert.Equal(http.StatusOK, entry.Response.Status)
+	assert.Equal(`{"status":"ok!"}`, entry.Response.Content.Text)
+	assert.Equal("", entry.Response.Content.Encoding)
+}
+
+func TestHAREntryBinaryBody(t *testing.T) {
+	assert := assert.New(t)
+
+	entry := NewHAREntry(New().WithURL("http://localhost/").Meta(), okMeta(), []byte{0xff, 0xfe})
+	assert.Equal("base64", entry.Response.Content.Encoding)
+	assert.Equal("//4=", entry.Response.Content.Text)
+	assert.Equal(int64(2), entry.Response.Content.Size)
+}
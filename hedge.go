@@ -0,0 +1,186 @@
+package request
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// WithHedging sends up to `maxExtra` duplicate attempts of the request, one every `delay` that passes
+// without a response, and uses whichever response arrives first; the other attempts are canceled.
+// Hedging only applies to `GET`, `HEAD` and `OPTIONS` requests, as it may send a request more than once.
+// The attempt that produced the response is reported as `ResponseMeta.HedgeAttempt`.
+// With a load balancer, extra attempts are sent to other hosts while there are hosts left to try.
+// Remarks: the attempts of a hedged request share the single rate limit token and circuit breaker check
+// of the request; extra attempts do not take tokens of their own.
+func (hr *Request) WithHedging(delay time.Duration, maxExtra int) *Request {
+	hr.hedgeDelay = delay
+	hr.hedgeMaxExtra = maxExtra
+	return hr
+}
+
+func (hr *Request) isHedged() bool {
+	if hr.hedgeMaxExtra < 1 {
+		return false
+	}
+	switch hr.Verb {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	}
+	return false
+}
+
+type hedgeResult struct {
+	attempt int
+	// host is set for extra attempts sent to another host of the load balancer.
+	host   string
+	res    *http.Response
+	err    error
+	cancel context.CancelFunc
+}
+
+// doHedged runs the hedged attempts and returns the winning response.
+func (hr *Request) doHedged(client *http.Client) (*http.Response, error) {
+	parent := hr.Context()
+	results := make(chan hedgeResult, hr.hedgeMaxExtra+1)
+
+	attempts := 0
+	inFlight := 0
+	cancels := map[int]context.CancelFunc{}
+	hosts := map[string]bool{hr.resolvedHost: true}
+	launch := func() error {
+		req, err := hr.Request()
+		if err != nil {
+			return err
+		}
+		var host string
+		if attempts > 0 && hr.loadBalancer != nil {
+			// a slow host is likely to stay slow, so extra attempts go elsewhere when they can.
+			if picked, pickErr := hr.loadBalancer.pick(hosts); pickErr == nil {
+				host = picked
+				hosts[host] = true
+				req.URL.Host = host
+				req.Host = host
+			}
+		}
+		attempts++
+		inFlight++
+		attempt := attempts
+		ctx, cancel := context.WithCancel(parent)
+		cancels[attempt] = cancel
+		go func() {
+			req, phases := traceTimeoutPhases(req.WithContext(ctx))
+			res, err := client.Do(req)
+			if err != nil {
+				err = hr.timeoutError(err, phases.current())
+			}
+			results <- hedgeResult{attempt: attempt, host: host, res: res, err: err, cancel: cancel}
+		}()
+		return nil
+	}
+
+	if err := launch(); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(hr.hedgeDelay)
+	defer timer.Stop()
+
+	canLaunch := func() bool {
+		return attempts <= hr.hedgeMaxExtra && parent.Err() == nil
+	}
+
+	var fallback *hedgeResult
+	var lastErr error
+	for inFlight > 0 {
+		select {
+		case <-timer.C:
+			if canLaunch() {
+				if err := launch(); err != nil {
+					lastErr = err
+				}
+				timer.Reset(hr.hedgeDelay)
+			}
+		case result := <-results:
+			inFlight--
+			delete(cancels, result.attempt)
+			hr.releaseHedgeHost(result, result.failed() && parent.Err() == nil)
+
+			if result.err == nil && result.res.StatusCode < http.StatusInternalServerError {
+				if fallback != nil {
+					fallback.close()
+				}
+				hr.finishHedge(cancels, results, inFlight)
+				return hr.hedgeWinner(result), nil
+			}
+
+			if result.err != nil {
+				lastErr = result.err
+				result.cancel()
+			} else if fallback == nil {
+				fallback = &result
+			} else {
+				result.close()
+			}
+
+			// a failed attempt is hedged right away instead of waiting out the delay.
+			if inFlight == 0 && canLaunch() {
+				if err := launch(); err != nil {
+					lastErr = err
+				}
+			}
+		}
+	}
+
+	// every attempt failed; prefer a server error response over a transport error.
+	if fallback != nil {
+		return hr.hedgeWinner(*fallback), nil
+	}
+	return nil, lastErr
+}
+
+// hedgeWinner records the winning attempt and cancels its context once the body is closed.
+func (hr *Request) hedgeWinner(result hedgeResult) *http.Response {
+	hr.hedgeAttempt = result.attempt
+	if !isEmpty(result.host) {
+		hr.resolvedHost = result.host
+	}
+	result.res.Body = &closeNotifier{ReadCloser: result.res.Body, onClose: result.cancel}
+	return result.res
+}
+
+// finishHedge cancels the attempts still in flight and closes their responses as they arrive.
+func (hr *Request) finishHedge(cancels map[int]context.CancelFunc, results chan hedgeResult, inFlight int) {
+	for _, cancel := range cancels {
+		cancel()
+	}
+	go func() {
+		for ; inFlight > 0; inFlight-- {
+			result := <-results
+			hr.releaseHedgeHost(result, false)
+			if result.res != nil {
+				result.res.Body.Close()
+			}
+		}
+	}()
+}
+
+func (hr hedgeResult) close() {
+	hr.res.Body.Close()
+	hr.cancel()
+}
+
+// releaseHedgeHost reports the outcome of an extra attempt to the load balancer host it was sent to.
+func (hr *Request) releaseHedgeHost(result hedgeResult, failed bool) {
+	if !isEmpty(result.host) {
+		hr.loadBalancer.done(result.host, failed)
+	}
+}
+
+// failed returns if the attempt says the host it was sent to is unhealthy.
+func (hr hedgeResult) failed() bool {
+	if hr.err != nil {
+		return isTransportError(hr.err)
+	}
+	return hr.res.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,120 @@
+package request
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func TestHedgingUsesFastestAttempt(t *testing.T) {
+	assert := assert.New(t)
+
+	var calls int32
+	var canceled int32
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+				atomic.AddInt32(&canceled, 1)
+				return
+			}
+		}
+		writeJSON(w, okMeta(), statusOkObject())
+	})
+	defer ts.Close()
+
+	var status statusObject
+	started := time.Now()
+	meta, err := New().WithURL(ts.URL).WithHedging(20*time.Millisecond, 2).JSONWithMeta(&status)
+	assert.Nil(err)
+	assert.True(time.Since(started) < 500*time.Millisecond)
+	assert.Equal("ok!", status.Status)
+	assert.Equal(2, meta.HedgeAttempt)
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&canceled) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&canceled))
+}
+
+func TestHedgingFirstAttemptWins(t *testing.T) {
+	assert := assert.New(t)
+
+	var calls int32
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		writeJSON(w, okMeta(), statusOkObject())
+	})
+	defer ts.Close()
+
+	meta, err := New().WithURL(ts.URL).WithHedging(time.Second, 2).ExecuteWithMeta()
+	assert.Nil(err)
+	assert.Equal(1, meta.HedgeAttempt)
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestHedgingServerErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	var calls int32
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		writeJSON(w, errorMeta(), statusOkObject())
+	})
+	defer ts.Close()
+
+	meta, err := New().WithURL(ts.URL).WithHedging(time.Second, 2).ExecuteWithMeta()
+	assert.Nil(err)
+	assert.Equal(http.StatusInternalServerError, meta.StatusCode)
+	assert.Equal(1, meta.HedgeAttempt)
+	assert.Equal(int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestHedgingSkipsNonIdempotentVerbs(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False(New().AsPost().WithHedging(time.Millisecond, 2).isHedged())
+	assert.True(New().AsGet().WithHedging(time.Millisecond, 2).isHedged())
+	assert.False(New().AsGet().isHedged())
+}
+
+func TestHedgingWithLoadBalancer(t *testing.T) {
+	assert := assert.New(t)
+
+	slow := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+			return
+		}
+		writeJSON(w, okMeta(), statusOkObject())
+	})
+	defer slow.Close()
+	fast := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer fast.Close()
+
+	lb := NewLoadBalancer(hostOf(slow.URL), hostOf(fast.URL))
+	var respondedHost string
+	var status statusObject
+	started := time.Now()
+	meta, err := New().WithURL("http://service/").
+		WithLoadBalancer(lb).
+		WithHedging(20*time.Millisecond, 1).
+		OnResponse(func(req *Meta, _ *ResponseMeta, _ []byte) {
+			respondedHost = req.URL.Host
+		}).
+		JSONWithMeta(&status)
+	assert.Nil(err)
+	assert.True(time.Since(started) < 500*time.Millisecond)
+	assert.Equal("ok!", status.Status)
+	assert.Equal(2, meta.HedgeAttempt)
+	assert.Equal(hostOf(fast.URL), respondedHost)
+	assert.True(lb.Healthy(hostOf(slow.URL)))
+	assert.True(lb.Healthy(hostOf(fast.URL)))
+}
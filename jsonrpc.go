@@ -0,0 +1,230 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+const (
+	// RPCVersion is the JSON-RPC protocol version sent with every call.
+	RPCVersion = "2.0"
+
+	// RPCErrorParse is the JSON-RPC error code for invalid json.
+	RPCErrorParse = -32700
+	// RPCErrorInvalidRequest is the JSON-RPC error code for an invalid request object.
+	RPCErrorInvalidRequest = -32600
+	// RPCErrorMethodNotFound is the JSON-RPC error code for an unknown method.
+	RPCErrorMethodNotFound = -32601
+	// RPCErrorInvalidParams is the JSON-RPC error code for invalid method parameters.
+	RPCErrorInvalidParams = -32602
+	// RPCErrorInternal is the JSON-RPC error code for an internal server error.
+	RPCErrorInternal = -32603
+)
+
+var rpcID uint64
+
+// RPCError is a JSON-RPC error object.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Error implements error.
+func (re *RPCError) Error() string {
+	return fmt.Sprintf("request: rpc error %d: %s", re.Code, re.Message)
+}
+
+func (re *RPCError) isTyped() {}
+
+// DataAs unmarshals the error data to an object.
+func (re *RPCError) DataAs(destination interface{}) error {
+	if len(re.Data) == 0 {
+		return nil
+	}
+	return wrap(json.Unmarshal(re.Data, destination))
+}
+
+// NewRPCCall returns a JSON-RPC call whose result is unmarshaled to an object.
+func NewRPCCall(method string, params interface{}, result interface{}) *RPCCall {
+	return &RPCCall{
+		ID:     atomic.AddUint64(&rpcID, 1),
+		Method: method,
+		Params: params,
+		Result: result,
+	}
+}
+
+// NewRPCNotification returns a JSON-RPC notification, a call without an id that the server does not respond to.
+func NewRPCNotification(method string, params interface{}) *RPCCall {
+	return &RPCCall{
+		Method:       method,
+		Params:       params,
+		Notification: true,
+	}
+}
+
+// RPCCall is a single call of a JSON-RPC request.
+// After the request is sent its result is unmarshaled to `Result`, and `Err` holds its error, if any.
+type RPCCall struct {
+	ID           uint64
+	Method       string
+	Params       interface{}
+	Result       interface{}
+	Notification bool
+
+	Err error
+}
+
+// MarshalJSON implements json.Marshaler.
+func (rc *RPCCall) MarshalJSON() ([]byte, error) {
+	call := rpcRequest{
+		JSONRPC: RPCVersion,
+		Method:  rc.Method,
+		Params:  rc.Params,
+	}
+	if !rc.Notification {
+		call.ID = &rc.ID
+	}
+	return json.Marshal(call)
+}
+
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	ID      *uint64     `json:"id,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+	ID      json.RawMessage `json:"id"`
+}
+
+func (rr rpcResponse) id() string {
+	return string(bytes.TrimSpace(rr.ID))
+}
+
+// RPC posts a JSON-RPC call and unmarshals its result to an object.
+// A JSON-RPC error response is returned as an `*RPCError`.
+func (hr *Request) RPC(method string, params interface{}, result interface{}) error {
+	_, err := hr.RPCWithMeta(method, params, result)
+	return err
+}
+
+// RPCWithMeta posts a JSON-RPC call and unmarshals its result to an object with metadata.
+func (hr *Request) RPCWithMeta(method string, params interface{}, result interface{}) (*ResponseMeta, error) {
+	call := NewRPCCall(method, params, result)
+	meta, err := hr.RPCBatch(call)
+	if err != nil {
+		return meta, err
+	}
+	return meta, call.Err
+}
+
+// RPCBatch posts JSON-RPC calls as a single batch and matches the responses back to the calls by id.
+// Each call gets its own result or `Err`; the returned error is a request error or the first call error, in order.
+// An error status without any JSON-RPC responses in the body is returned as a request error.
+func (hr *Request) RPCBatch(calls ...*RPCCall) (*ResponseMeta, error) {
+	if len(calls) == 0 {
+		return nil, exception.New("request: rpc batch has no calls")
+	}
+
+	var payload interface{} = calls
+	if len(calls) == 1 {
+		payload = calls[0]
+	}
+
+	req := hr.clone().AsPost().WithPostBodyAsJSON(payload)
+	var body []byte
+	meta, err := req.deserialize(func(contents []byte) error {
+		body = contents
+		return nil
+	})
+	if err != nil {
+		return meta, err
+	}
+
+	responses, err := parseRPCResponses(body)
+	if (err != nil || len(responses) == 0) && (meta.StatusCode < http.StatusOK || meta.StatusCode >= http.StatusMultipleChoices) {
+		// an error status without json-rpc responses is an http error, e.g. from a proxy in front of the server.
+		err = exception.Newf("request: rpc: unexpected status code %d", meta.StatusCode)
+		req.logError(err, ErrorClassUnknown)
+		return meta, err
+	}
+	if err != nil {
+		req.logError(err, ErrorClassDecode)
+		return meta, wrap(err)
+	}
+
+	byID := map[string]rpcResponse{}
+	for _, response := range responses {
+		if response.Error != nil && (len(response.ID) == 0 || response.id() == "null") {
+			// the server could not read the request, so the error applies to every call.
+			req.logError(response.Error, ErrorClassRPC)
+			return meta, response.Error
+		}
+		byID[response.id()] = response
+	}
+
+	var firstErr error
+	for _, call := range calls {
+		if call.Notification {
+			continue
+		}
+		call.Err = req.rpcResult(call, byID)
+		if call.Err != nil && firstErr == nil {
+			firstErr = call.Err
+		}
+	}
+	return meta, firstErr
+}
+
+// parseRPCResponses parses a single json-rpc response or a batch of them; an empty body has none.
+func parseRPCResponses(body []byte) ([]rpcResponse, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, nil
+	}
+	var responses []rpcResponse
+	if body[0] == '[' {
+		if err := json.Unmarshal(body, &responses); err != nil {
+			return nil, err
+		}
+		return responses, nil
+	}
+	var response rpcResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	return []rpcResponse{response}, nil
+}
+
+func (hr *Request) rpcResult(call *RPCCall, responses map[string]rpcResponse) error {
+	response, hasResponse := responses[strconv.FormatUint(call.ID, 10)]
+	if !hasResponse {
+		err := exception.Newf("request: rpc call %d (%s) has no response", call.ID, call.Method)
+		hr.logError(err, ErrorClassRPC)
+		return err
+	}
+	if response.Error != nil {
+		hr.logError(response.Error, ErrorClassRPC)
+		return response.Error
+	}
+	if call.Result == nil || len(response.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(response.Result, call.Result); err != nil {
+		hr.logError(err, ErrorClassDecode)
+		return wrap(err)
+	}
+	return nil
+}
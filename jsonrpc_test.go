@@ -0,0 +1,206 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+type rpcTestCall struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  []int           `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcEndpoint serves `add` and `fail` methods, answering batches in reverse order.
+func rpcEndpoint(received *[]rpcTestCall) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		var calls []rpcTestCall
+		isBatch := len(body) > 0 && body[0] == '['
+		if isBatch {
+			json.Unmarshal(body, &calls)
+		} else {
+			var call rpcTestCall
+			json.Unmarshal(body, &call)
+			calls = []rpcTestCall{call}
+		}
+		if received != nil {
+			*received = append(*received, calls...)
+		}
+
+		var responses []interface{}
+		for index := len(calls) - 1; index >= 0; index-- {
+			call := calls[index]
+			if len(call.ID) == 0 {
+				continue
+			}
+			switch call.Method {
+			case "add":
+				var sum int
+				for _, param := range call.Params {
+					sum += param
+				}
+				responses = append(responses, map[string]interface{}{"jsonrpc": "2.0", "result": sum, "id": call.ID})
+			default:
+				responses = append(responses, map[string]interface{}{"jsonrpc": "2.0", "id": call.ID, "error": map[string]interface{}{
+					"code": RPCErrorMethodNotFound, "message": "Method not found", "data": map[string]interface{}{"method": call.Method},
+				}})
+			}
+		}
+
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if isBatch {
+			writeJSON(w, okMeta(), responses)
+		} else {
+			writeJSON(w, okMeta(), responses[0])
+		}
+	}
+}
+
+func TestRPC(t *testing.T) {
+	assert := assert.New(t)
+
+	var received []rpcTestCall
+	ts := getMockServer(rpcEndpoint(&received))
+	defer ts.Close()
+
+	var sum int
+	err := New().WithURL(ts.URL).RPC("add", []int{1, 2, 3}, &sum)
+	assert.Nil(err)
+	assert.Equal(6, sum)
+	assert.Len(received, 1)
+	assert.Equal(RPCVersion, received[0].JSONRPC)
+	assert.NotEmpty(received[0].ID)
+}
+
+func TestRPCError(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(rpcEndpoint(nil))
+	defer ts.Close()
+
+	var class ErrorClass
+	err := New().WithURL(ts.URL).OnError(func(req *Meta, err error) {
+		class = ClassifyError(err)
+	}).RPC("missing", nil, nil)
+	assert.NotNil(err)
+	assert.Equal(ErrorClassRPC, class)
+
+	var rpcErr *RPCError
+	assert.True(errors.As(err, &rpcErr))
+	assert.Equal(RPCErrorMethodNotFound, rpcErr.Code)
+	assert.Equal("Method not found", rpcErr.Message)
+
+	var data struct {
+		Method string `json:"method"`
+	}
+	assert.Nil(rpcErr.DataAs(&data))
+	assert.Equal("missing", data.Method)
+}
+
+func TestRPCBatch(t *testing.T) {
+	assert := assert.New(t)
+
+	var received []rpcTestCall
+	ts := getMockServer(rpcEndpoint(&received))
+	defer ts.Close()
+
+	var first, second int
+	calls := []*RPCCall{
+		NewRPCCall("add", []int{1, 2}, &first),
+		NewRPCNotification("add", []int{5}),
+		NewRPCCall("missing", nil, nil),
+		NewRPCCall("add", []int{3, 4}, &second),
+	}
+	_, err := New().WithURL(ts.URL).RPCBatch(calls...)
+	assert.NotNil(err)
+	assert.Equal(calls[2].Err, err)
+	assert.Len(received, 4)
+	assert.Empty(received[1].ID)
+
+	assert.Nil(calls[0].Err)
+	assert.Equal(3, first)
+	assert.Nil(calls[1].Err)
+	assert.NotNil(calls[2].Err)
+	assert.Nil(calls[3].Err)
+	assert.Equal(7, second)
+}
+
+func TestRPCBatchNotifications(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(rpcEndpoint(nil))
+	defer ts.Close()
+
+	meta, err := New().WithURL(ts.URL).RPCBatch(NewRPCNotification("add", []int{1}), NewRPCNotification("add", []int{2}))
+	assert.Nil(err)
+	assert.Equal(http.StatusNoContent, meta.StatusCode)
+}
+
+func TestRPCBatchMissingResponse(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(okMeta(), []interface{}{}, nil)
+	defer ts.Close()
+
+	call := NewRPCCall("add", []int{1}, nil)
+	_, err := New().WithURL(ts.URL).RPCBatch(call, NewRPCCall("add", []int{2}, nil))
+	assert.NotNil(err)
+	assert.NotNil(call.Err)
+}
+
+func TestRPCInvalidRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(okMeta(), map[string]interface{}{"jsonrpc": "2.0", "id": nil, "error": map[string]interface{}{"code": RPCErrorParse, "message": "Parse error"}}, nil)
+	defer ts.Close()
+
+	err := New().WithURL(ts.URL).RPC("add", nil, nil)
+	var rpcErr *RPCError
+	assert.True(errors.As(err, &rpcErr))
+	assert.Equal(RPCErrorParse, rpcErr.Code)
+}
+
+func TestRPCBatchStatusError(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, body := range []string{"", "<html>Bad Gateway</html>"} {
+		ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(body))
+		})
+
+		first := NewRPCCall("add", []int{1}, nil)
+		meta, err := New().WithURL(ts.URL).RPCBatch(first, NewRPCCall("add", []int{2}, nil))
+		ts.Close()
+		assert.NotNil(err)
+		assert.Equal(http.StatusBadGateway, meta.StatusCode)
+		assert.Nil(first.Err)
+		var rpcErr *RPCError
+		assert.False(errors.As(err, &rpcErr))
+	}
+}
+
+func TestRPCBatchDoesNotChangeRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(rpcEndpoint(nil))
+	defer ts.Close()
+
+	req := New().WithURL(ts.URL)
+	var sum int
+	assert.Nil(req.RPC("add", []int{1, 2}, &sum))
+	assert.Equal(3, sum)
+	assert.Equal("GET", req.Verb)
+	assert.Empty(req.PostBody())
+}
@@ -1,8 +1,10 @@
 package request
 
 import (
+	"context"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -39,11 +41,15 @@ func NewMetaWithBody(req *http.Request) (*Meta, error) {
 
 // Meta is a summary of the request meta useful for logging.
 type Meta struct {
-	StartTime time.Time
-	Verb      string
-	URL       *url.URL
-	Headers   http.Header
-	Body      []byte
+	StartTime    time.Time
+	Label        string
+	Attempt      int
+	Verb         string
+	URL          *url.URL
+	PathTemplate string
+	Headers      http.Header
+	Cookies      []*http.Cookie
+	Body         []byte
 }
 
 //--------------------------------------------------------------------------------
@@ -73,22 +79,57 @@ func NewResponseMeta(res *http.Response) *ResponseMeta {
 	}
 
 	meta.Headers = res.Header
+	if res.Request != nil {
+		meta.URL = res.Request.URL
+	}
+	meta.Redirects = redirectMetas(res)
 	return meta
 }
 
 // ResponseMeta is just the meta information for an http response.
+// `URL` is the url the response was requested from, with the host that was dialled.
 type ResponseMeta struct {
+	URL             *url.URL
 	CompleteTime    time.Time
 	StatusCode      int
 	ContentLength   int64
 	ContentEncoding string
 	ContentType     string
 	Headers         http.Header
+	Redirects       []*Meta
+	HedgeAttempt    int
+}
+
+// RequestKey selects how requests are grouped, i.e. into circuits or rate limit buckets.
+type RequestKey string
+
+const (
+	// RequestKeyHost groups requests by host.
+	RequestKeyHost RequestKey = "host"
+	// RequestKeyLabel groups requests by label, falling back to the host for unlabeled requests.
+	RequestKeyLabel RequestKey = "label"
+)
+
+// Key returns the group key for a request.
+// While the request is being sent, requests keyed by host use the host that is dialled,
+// i.e. the one chosen by the load balancer or service discovery, so each backend has its own group;
+// otherwise they use the configured `Host`.
+func (rk RequestKey) Key(req *Request) string {
+	if rk == RequestKeyLabel && !isEmpty(req.Label) {
+		return req.Label
+	}
+	if !isEmpty(req.resolvedHost) {
+		return req.resolvedHost
+	}
+	return req.Host
 }
 
 // CreateTransportHandler is a receiver for `OnCreateTransport`.
 type CreateTransportHandler func(host *url.URL, transport *http.Transport)
 
+// DialFunc opens a connection for the request transport, see `WithDialer`.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
 // ResponseHandler is a receiver for `OnResponse`.
 type ResponseHandler func(req *Meta, meta *ResponseMeta, content []byte)
 
@@ -98,6 +139,9 @@ type StatefulResponseHandler func(req *Meta, res *ResponseMeta, content []byte,
 // OutgoingRequestHandler is a receiver for `OnRequest`.
 type OutgoingRequestHandler func(req *Meta)
 
+// ErrorHandler is a receiver for `OnError`.
+type ErrorHandler func(req *Meta, err error)
+
 // MockedResponseProvider is a mocked response provider.
 type MockedResponseProvider func(*Request) *MockedResponse
 
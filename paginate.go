@@ -0,0 +1,379 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+// PaginationStrategy moves a request from one page to the next.
+type PaginationStrategy interface {
+	// First prepares the request for the first page.
+	First(req *Request)
+	// Next updates the request for the page after the one just read, or returns false if it was the last page.
+	Next(req *Request, meta *ResponseMeta, body []byte) (bool, error)
+}
+
+// Paginate returns an iterator over the pages of a request.
+// The prototype is copied for each page and is not modified.
+//
+//	pages := request.Paginate(request.Get(url), request.NewLinkPagination())
+//	for pages.Next() {
+//		var items []Item
+//		if err := pages.JSON(&items); err != nil {
+//			return err
+//		}
+//	}
+//	return pages.Err()
+func Paginate(prototype *Request, strategy PaginationStrategy) *Paginator {
+	return &Paginator{
+		prototype: prototype,
+		strategy:  strategy,
+	}
+}
+
+// Paginator iterates over the pages of a request.
+type Paginator struct {
+	prototype *Request
+	strategy  PaginationStrategy
+	ctx       context.Context
+	maxPages  int
+
+	next *Request
+	page int
+	done bool
+	body []byte
+	meta *ResponseMeta
+	err  error
+}
+
+// WithMaxPages stops the iterator after a number of pages; zero means no limit.
+func (p *Paginator) WithMaxPages(maxPages int) *Paginator {
+	p.maxPages = maxPages
+	return p
+}
+
+// WithContext sets a context for every page request; iteration stops with its error when it is done.
+func (p *Paginator) WithContext(ctx context.Context) *Paginator {
+	p.ctx = ctx
+	return p
+}
+
+// Next fetches the next page and returns true, or returns false if there are no more pages or an error occurred.
+func (p *Paginator) Next() bool {
+	if p.done {
+		return false
+	}
+	if p.maxPages > 0 && p.page >= p.maxPages {
+		p.done = true
+		return false
+	}
+	if p.next == nil {
+		p.next = p.prototype.clone()
+		p.strategy.First(p.next)
+	}
+	if p.ctx != nil {
+		if err := p.ctx.Err(); err != nil {
+			return p.fail(err)
+		}
+		p.next.WithContext(p.ctx)
+	}
+
+	current := p.next
+	body, meta, err := current.BytesWithMeta()
+	if err != nil {
+		return p.fail(err)
+	}
+	if meta.StatusCode < http.StatusOK || meta.StatusCode >= http.StatusMultipleChoices {
+		p.meta = meta
+		return p.fail(exception.Newf("pagination: unexpected status code %d for page %d", meta.StatusCode, p.page+1))
+	}
+
+	p.page++
+	p.body = body
+	p.meta = meta
+
+	p.next = current.clone()
+	more, err := p.strategy.Next(p.next, meta, body)
+	if err != nil {
+		p.err = wrap(err)
+		p.done = true
+	} else if !more || samePage(current, p.next) {
+		// a server that sends the same link or cursor again would otherwise be paged forever.
+		p.done = true
+	}
+	return true
+}
+
+// Page returns the number of the current page, starting at 1.
+func (p *Paginator) Page() int {
+	return p.page
+}
+
+// Body returns the body of the current page.
+func (p *Paginator) Body() []byte {
+	return p.body
+}
+
+// Meta returns the response meta of the current page.
+func (p *Paginator) Meta() *ResponseMeta {
+	return p.meta
+}
+
+// JSON unmarshals the current page as json to an object.
+func (p *Paginator) JSON(destination interface{}) error {
+	return wrap(deserializeJSON(destination, p.body))
+}
+
+// XML unmarshals the current page as xml to an object.
+func (p *Paginator) XML(destination interface{}) error {
+	return wrap(deserializeXML(destination, p.body))
+}
+
+// Err returns the error that stopped the iterator, if any.
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+func (p *Paginator) fail(err error) bool {
+	p.err = wrap(err)
+	p.done = true
+	return false
+}
+
+// samePage returns if two page requests would fetch the same page.
+func samePage(current, next *Request) bool {
+	return current.Verb == next.Verb &&
+		current.configuredURL().String() == next.configuredURL().String() &&
+		bytes.Equal(current.PostBody(), next.PostBody())
+}
+
+// NewLinkPagination returns a strategy that follows RFC 5988 `Link: <url>; rel="next"` response headers.
+func NewLinkPagination() *LinkPagination {
+	return &LinkPagination{Rel: "next"}
+}
+
+// LinkPagination follows the url of a `Link` header relation.
+// Links to another scheme or host are refused with an error, so the credentials and cookies
+// of the request are only ever sent to the origin it was made to. The host that answered the page,
+// i.e. the one chosen by the load balancer or service discovery, counts as that origin.
+type LinkPagination struct {
+	Rel string
+}
+
+// First implements PaginationStrategy.
+func (lp *LinkPagination) First(req *Request) {}
+
+// Next implements PaginationStrategy.
+func (lp *LinkPagination) Next(req *Request, meta *ResponseMeta, body []byte) (bool, error) {
+	target, found := findLink(meta.Headers[http.CanonicalHeaderKey("Link")], lp.Rel)
+	if !found {
+		return false, nil
+	}
+	current := req.URL()
+	next, err := current.Parse(target)
+	if err != nil {
+		return false, err
+	}
+	if next.Scheme != current.Scheme || (next.Host != current.Host && next.Host != answeredHost(meta)) {
+		return false, exception.Newf("pagination: refusing to follow link to %s://%s from %s://%s", next.Scheme, next.Host, current.Scheme, current.Host)
+	}
+	// the scheme and host are kept as configured, i.e. for `srv://` urls.
+	req.Path = next.Path
+	req.PathTemplate = ""
+	req.QueryString = next.Query()
+	return true, nil
+}
+
+// answeredHost returns the host the request for a page was sent to before any redirects.
+func answeredHost(meta *ResponseMeta) string {
+	if len(meta.Redirects) > 0 {
+		return meta.Redirects[0].URL.Host
+	}
+	if meta.URL != nil {
+		return meta.URL.Host
+	}
+	return ""
+}
+
+// findLink returns the target of the first link with a relation in a set of `Link` header values.
+func findLink(values []string, rel string) (string, bool) {
+	for _, value := range values {
+		for _, link := range splitLinks(value) {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				name, paramValue := splitParam(param)
+				if !strings.EqualFold(name, "rel") {
+					continue
+				}
+				for _, linkRel := range strings.Fields(paramValue) {
+					if strings.EqualFold(linkRel, rel) {
+						return target[1 : len(target)-1], true
+					}
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// splitLinks splits a `Link` header value on the commas between links, ignoring commas inside `<>` or quotes.
+func splitLinks(value string) []string {
+	var links []string
+	var inURL, inQuote bool
+	start := 0
+	for index, r := range value {
+		switch {
+		case r == '<' && !inQuote:
+			inURL = true
+		case r == '>' && !inQuote:
+			inURL = false
+		case r == '"' && !inURL:
+			inQuote = !inQuote
+		case r == ',' && !inURL && !inQuote:
+			links = append(links, value[start:index])
+			start = index + 1
+		}
+	}
+	return append(links, value[start:])
+}
+
+func splitParam(param string) (string, string) {
+	pieces := strings.SplitN(param, "=", 2)
+	if len(pieces) != 2 {
+		return strings.TrimSpace(pieces[0]), ""
+	}
+	return strings.TrimSpace(pieces[0]), strings.Trim(strings.TrimSpace(pieces[1]), `"`)
+}
+
+// NewCursorPagination returns a strategy that reads a cursor from a json field of each page
+// and sends it as a query string parameter for the next page. The field can be a dotted path, e.g. `meta.next_cursor`.
+func NewCursorPagination(field, param string) *CursorPagination {
+	return &CursorPagination{Field: field, Param: param}
+}
+
+// CursorPagination passes a cursor from each page body to the next request.
+// Pagination stops when the cursor is missing, null or empty.
+type CursorPagination struct {
+	Field string
+	Param string
+}
+
+// First implements PaginationStrategy.
+func (cp *CursorPagination) First(req *Request) {}
+
+// Next implements PaginationStrategy.
+func (cp *CursorPagination) Next(req *Request, meta *ResponseMeta, body []byte) (bool, error) {
+	value, err := jsonField(body, cp.Field)
+	if err != nil {
+		return false, err
+	}
+
+	var cursor string
+	switch typed := value.(type) {
+	case nil:
+		return false, nil
+	case string:
+		cursor = typed
+	case json.Number:
+		cursor = typed.String()
+	default:
+		return false, exception.Newf("pagination: cursor field %q is not a string or number", cp.Field)
+	}
+	if isEmpty(cursor) {
+		return false, nil
+	}
+	setQuery(req, cp.Param, cursor)
+	return true, nil
+}
+
+// NewOffsetPagination returns a strategy that pages with offset and limit query string parameters.
+func NewOffsetPagination(offsetParam, limitParam string, limit int) *OffsetPagination {
+	return &OffsetPagination{OffsetParam: offsetParam, LimitParam: limitParam, Limit: limit}
+}
+
+// OffsetPagination advances an offset query string parameter by the page size.
+// Pagination stops when a page has fewer items than the limit; items are counted from the json array
+// at `ItemsField`, or the body itself if it is empty.
+type OffsetPagination struct {
+	OffsetParam string
+	LimitParam  string
+	Limit       int
+	ItemsField  string
+}
+
+// WithItemsField sets the dotted path of the json array that holds the items of a page.
+func (op *OffsetPagination) WithItemsField(field string) *OffsetPagination {
+	op.ItemsField = field
+	return op
+}
+
+// First implements PaginationStrategy.
+func (op *OffsetPagination) First(req *Request) {
+	if req.QueryString == nil || isEmpty(req.QueryString.Get(op.OffsetParam)) {
+		setQuery(req, op.OffsetParam, "0")
+	}
+	if !isEmpty(op.LimitParam) {
+		setQuery(req, op.LimitParam, strconv.Itoa(op.Limit))
+	}
+}
+
+// Next implements PaginationStrategy.
+func (op *OffsetPagination) Next(req *Request, meta *ResponseMeta, body []byte) (bool, error) {
+	value, err := jsonField(body, op.ItemsField)
+	if err != nil {
+		return false, err
+	}
+	items, isArray := value.([]interface{})
+	if !isArray && value != nil {
+		return false, exception.Newf("pagination: items field %q is not an array", op.ItemsField)
+	}
+	if len(items) == 0 || len(items) < op.Limit {
+		return false, nil
+	}
+
+	offset, err := strconv.Atoi(req.QueryString.Get(op.OffsetParam))
+	if err != nil {
+		return false, exception.Newf("pagination: invalid offset %q", req.QueryString.Get(op.OffsetParam))
+	}
+	setQuery(req, op.OffsetParam, strconv.Itoa(offset+len(items)))
+	return true, nil
+}
+
+// jsonField returns the value at a dotted path in a json document; an empty path returns the whole document.
+func jsonField(body []byte, path string) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if isEmpty(path) {
+		return value, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		object, isObject := value.(map[string]interface{})
+		if !isObject {
+			return nil, nil
+		}
+		value = object[key]
+	}
+	return value, nil
+}
+
+func setQuery(req *Request, field, value string) {
+	if req.QueryString == nil {
+		req.QueryString = url.Values{}
+	}
+	req.QueryString.Set(field, value)
+}
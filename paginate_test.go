@@ -0,0 +1,218 @@
+package request
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func TestPaginateLinkHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=3>; rel="last"`, page+1))
+		}
+		writeJSON(w, okMeta(), []int{page})
+	}))
+	defer ts.Close()
+
+	pages := Paginate(New().WithURL(ts.URL+"/items").WithQueryString("page", "1"), NewLinkPagination())
+	var items []int
+	for pages.Next() {
+		var page []int
+		assert.Nil(pages.JSON(&page))
+		items = append(items, page...)
+	}
+	assert.Nil(pages.Err())
+	assert.Equal([]int{1, 2, 3}, items)
+	assert.Equal(3, pages.Page())
+}
+
+func TestPaginateCursor(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			writeJSON(w, okMeta(), map[string]interface{}{"items": []string{"a", "b"}, "meta": map[string]interface{}{"next_cursor": "c1"}})
+		case "c1":
+			writeJSON(w, okMeta(), map[string]interface{}{"items": []string{"c"}, "meta": map[string]interface{}{"next_cursor": nil}})
+		default:
+			http.Error(w, "bad cursor", http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	prototype := New().WithURL(ts.URL)
+	pages := Paginate(prototype, NewCursorPagination("meta.next_cursor", "cursor"))
+	var items []string
+	for pages.Next() {
+		var page struct {
+			Items []string `json:"items"`
+		}
+		assert.Nil(pages.JSON(&page))
+		items = append(items, page.Items...)
+	}
+	assert.Nil(pages.Err())
+	assert.Equal([]string{"a", "b", "c"}, items)
+	assert.Empty(prototype.QueryString.Get("cursor"))
+}
+
+func TestPaginateOffset(t *testing.T) {
+	assert := assert.New(t)
+
+	all := []int{0, 1, 2, 3, 4}
+	var requests int
+	ts := getMockServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		end := offset + limit
+		if end > len(all) {
+			end = len(all)
+		}
+		writeJSON(w, okMeta(), all[offset:end])
+	}))
+	defer ts.Close()
+
+	pages := Paginate(New().WithURL(ts.URL), NewOffsetPagination("offset", "limit", 2))
+	var items []int
+	for pages.Next() {
+		var page []int
+		assert.Nil(pages.JSON(&page))
+		items = append(items, page...)
+	}
+	assert.Nil(pages.Err())
+	assert.Equal(all, items)
+	assert.Equal(3, requests)
+}
+
+func TestPaginateMaxPages(t *testing.T) {
+	assert := assert.New(t)
+
+	var requests int
+	ts := getMockServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeJSON(w, okMeta(), []int{1, 2})
+	}))
+	defer ts.Close()
+
+	pages := Paginate(New().WithURL(ts.URL), NewOffsetPagination("offset", "limit", 2)).WithMaxPages(2)
+	for pages.Next() {
+	}
+	assert.Nil(pages.Err())
+	assert.Equal(2, requests)
+}
+
+func TestPaginateLinkHeaderOtherOrigin(t *testing.T) {
+	assert := assert.New(t)
+
+	var authorizations []string
+	ts := getMockServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		w.Header().Set("Link", `<https://evil.test.com/items?page=2>; rel="next"`)
+		writeJSON(w, okMeta(), []int{1})
+	}))
+	defer ts.Close()
+
+	pages := Paginate(New().WithURL(ts.URL).WithHeader("Authorization", "Bearer secret"), NewLinkPagination())
+	assert.True(pages.Next())
+	assert.False(pages.Next())
+	assert.NotNil(pages.Err())
+	assert.Equal([]string{"Bearer secret"}, authorizations)
+}
+
+func TestPaginateLinkHeaderLoadBalanced(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 2 {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/items?page=%d>; rel="next"`, r.Host, page+1))
+		}
+		writeJSON(w, okMeta(), []int{page})
+	}))
+	defer ts.Close()
+
+	lb := NewLoadBalancer(hostOf(ts.URL))
+	pages := Paginate(New().WithURL("http://service/items").WithQueryString("page", "1").WithLoadBalancer(lb), NewLinkPagination())
+	var items []int
+	for pages.Next() {
+		var page []int
+		assert.Nil(pages.JSON(&page))
+		items = append(items, page...)
+	}
+	assert.Nil(pages.Err())
+	assert.Equal([]int{1, 2}, items)
+}
+
+func TestPaginateRepeatedPage(t *testing.T) {
+	assert := assert.New(t)
+
+	var requests int
+	ts := getMockServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Link", `</items?page=1>; rel="next"`)
+		writeJSON(w, okMeta(), map[string]interface{}{"cursor": "same"})
+	}))
+	defer ts.Close()
+
+	pages := Paginate(New().WithURL(ts.URL+"/items?page=1"), NewLinkPagination())
+	for pages.Next() {
+	}
+	assert.Nil(pages.Err())
+	assert.Equal(1, requests)
+
+	requests = 0
+	pages = Paginate(New().WithURL(ts.URL), NewCursorPagination("cursor", "cursor"))
+	for pages.Next() {
+	}
+	assert.Nil(pages.Err())
+	assert.Equal(2, requests)
+}
+
+func TestPaginateStatusError(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(errorMeta(), nil, nil)
+	defer ts.Close()
+
+	pages := Paginate(New().WithURL(ts.URL), NewLinkPagination())
+	assert.False(pages.Next())
+	assert.NotNil(pages.Err())
+	assert.Equal(http.StatusInternalServerError, pages.Meta().StatusCode)
+}
+
+func TestPaginateContext(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `</next>; rel="next"`)
+		writeJSON(w, okMeta(), []int{1})
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pages := Paginate(New().WithURL(ts.URL), NewLinkPagination()).WithContext(ctx)
+	assert.True(pages.Next())
+	cancel()
+	assert.False(pages.Next())
+	assert.NotNil(pages.Err())
+}
+
+func TestFindLink(t *testing.T) {
+	assert := assert.New(t)
+
+	target, found := findLink([]string{`<https://api.test.com/a?x=1,2>; rel="prev", <https://api.test.com/b>; title="a, b"; rel="next last"`}, "next")
+	assert.True(found)
+	assert.Equal("https://api.test.com/b", target)
+
+	_, found = findLink([]string{`<https://api.test.com/a>; rel="prev"`}, "next")
+	assert.False(found)
+}
@@ -0,0 +1,68 @@
+package request
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+// WithPathTemplate sets the path component of the host url from a template with named `{param}` segments,
+// e.g. `/api/v1/borrowers/{id}/docs/{docID}`. Parameter values are set with `WithPathParam`
+// and are escaped, so values with slashes or spaces stay in a single segment;
+// values of "." or ".." are rejected as they would change the segments around them.
+// The unexpanded template is kept on `Meta` as `PathTemplate`.
+func (hr *Request) WithPathTemplate(template string) *Request {
+	hr.PathTemplate = template
+	hr.Path = ""
+	return hr
+}
+
+// WithPathParam sets the value of a named parameter in the path template.
+func (hr *Request) WithPathParam(name, value string) *Request {
+	if hr.PathParams == nil {
+		hr.PathParams = map[string]string{}
+	}
+	hr.PathParams[name] = value
+	return hr
+}
+
+// expandPath returns the unescaped and escaped forms of the path template with its parameters filled in.
+func (hr *Request) expandPath() (string, string, error) {
+	path := bytes.NewBuffer(nil)
+	rawPath := bytes.NewBuffer(nil)
+
+	remaining := hr.PathTemplate
+	for len(remaining) > 0 {
+		start := strings.IndexRune(remaining, '{')
+		if start < 0 {
+			writePathLiteral(path, rawPath, remaining)
+			break
+		}
+		writePathLiteral(path, rawPath, remaining[:start])
+
+		end := strings.IndexRune(remaining[start:], '}')
+		if end < 0 {
+			return "", "", exception.Newf("path template %q has an unclosed parameter", hr.PathTemplate)
+		}
+		name := remaining[start+1 : start+end]
+		value, hasValue := hr.PathParams[name]
+		if isEmpty(name) || !hasValue {
+			return "", "", exception.Newf("path template %q is missing parameter %q", hr.PathTemplate, name)
+		}
+		// escaping leaves dot segments alone, and they would move the path out of the template.
+		if value == "." || value == ".." {
+			return "", "", exception.Newf("path template %q parameter %q cannot be a dot segment", hr.PathTemplate, name)
+		}
+		path.WriteString(value)
+		rawPath.WriteString(url.PathEscape(value))
+		remaining = remaining[start+end+1:]
+	}
+	return path.String(), rawPath.String(), nil
+}
+
+func writePathLiteral(path, rawPath *bytes.Buffer, literal string) {
+	path.WriteString(literal)
+	rawPath.WriteString((&url.URL{Path: literal}).EscapedPath())
+}
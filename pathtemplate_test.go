@@ -0,0 +1,70 @@
+package request
+
+import (
+	"net/http"
+	"testing"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func TestWithPathTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	req := New().WithHost("api.test.com").
+		WithPathTemplate("/api/v1/borrowers/{id}/docs/{docID}").
+		WithPathParam("id", "a/b c").
+		WithPathParam("docID", "42")
+	assert.Equal("http://api.test.com/api/v1/borrowers/a%2Fb%20c/docs/42", req.URL().String())
+	assert.Equal("/api/v1/borrowers/{id}/docs/{docID}", req.Meta().PathTemplate)
+}
+
+func TestWithPathTemplateRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	var requestURI string
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.RequestURI
+		writeJSON(w, okMeta(), statusOkObject())
+	})
+	defer ts.Close()
+
+	var status statusObject
+	err := New().WithURL(ts.URL).
+		WithPathTemplate("/docs/{name}").
+		WithPathParam("name", "q1/report.pdf").
+		WithQueryString("v", "1").
+		JSON(&status)
+	assert.Nil(err)
+	assert.Equal("/docs/q1%2Freport.pdf?v=1", requestURI)
+}
+
+func TestWithPathTemplateMissingParam(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := New().WithHost("api.test.com").WithPathTemplate("/docs/{id}").Request()
+	assert.NotNil(err)
+
+	_, err = New().WithHost("api.test.com").WithPathTemplate("/docs/{id").WithPathParam("id", "1").Request()
+	assert.NotNil(err)
+}
+
+func TestWithPathTemplateDotSegments(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, value := range []string{".", ".."} {
+		_, err := New().WithHost("api.test.com").WithPathTemplate("/users/{id}/docs").WithPathParam("id", value).Request()
+		assert.NotNil(err)
+	}
+
+	req, err := New().WithHost("api.test.com").WithPathTemplate("/users/{id}/docs").WithPathParam("id", "...").Request()
+	assert.Nil(err)
+	assert.Equal("/users/.../docs", req.URL.Path)
+}
+
+func TestWithPathClearsTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	req := New().WithHost("api.test.com").WithPathTemplate("/docs/{id}").WithPath("/status")
+	assert.Equal("http://api.test.com/status", req.URL().String())
+	assert.Empty(req.Meta().PathTemplate)
+}
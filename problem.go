@@ -0,0 +1,115 @@
+package request
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+)
+
+const (
+	// ContentTypeProblemJSON is the content type of RFC 7807 problem details.
+	ContentTypeProblemJSON = "application/problem+json"
+)
+
+// ProblemDetails is an RFC 7807 `application/problem+json` error response.
+// It is returned as the error from terminal methods when a response has the problem json content type,
+// and can be found with `errors.As`.
+type ProblemDetails struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Extensions are any other members of the problem object.
+	Extensions map[string]json.RawMessage `json:"-"`
+}
+
+// Error implements error.
+func (pd *ProblemDetails) Error() string {
+	message := fmt.Sprintf("request: problem (%d)", pd.Status)
+	if !isEmpty(pd.Title) {
+		message = message + " " + pd.Title
+	} else if !isEmpty(pd.Type) {
+		message = message + " " + pd.Type
+	}
+	if !isEmpty(pd.Detail) {
+		message = message + ": " + pd.Detail
+	}
+	return message
+}
+
+func (pd *ProblemDetails) isTyped() {}
+
+// Extension unmarshals an extension member into an object, and returns false if the member is not present.
+func (pd *ProblemDetails) Extension(name string, destination interface{}) (bool, error) {
+	raw, hasExtension := pd.Extensions[name]
+	if !hasExtension {
+		return false, nil
+	}
+	return true, wrap(json.Unmarshal(raw, destination))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, collecting unknown members into `Extensions`.
+func (pd *ProblemDetails) UnmarshalJSON(data []byte) error {
+	type problemDetails ProblemDetails
+	var standard problemDetails
+	if err := json.Unmarshal(data, &standard); err != nil {
+		return err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	for _, name := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(members, name)
+	}
+
+	*pd = ProblemDetails(standard)
+	if len(members) > 0 {
+		pd.Extensions = members
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, writing `Extensions` as top level members.
+func (pd ProblemDetails) MarshalJSON() ([]byte, error) {
+	type problemDetails ProblemDetails
+	standard, err := json.Marshal(problemDetails(pd))
+	if err != nil || len(pd.Extensions) == 0 {
+		return standard, err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(standard, &members); err != nil {
+		return nil, err
+	}
+	for name, value := range pd.Extensions {
+		if _, isStandard := members[name]; !isStandard {
+			members[name] = value
+		}
+	}
+	return json.Marshal(members)
+}
+
+// IsProblemJSON returns if a content type is `application/problem+json`.
+func IsProblemJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == ContentTypeProblemJSON
+}
+
+// problemError returns the problem details of a problem json response as an error, or nil for any other response.
+func (hr *Request) problemError(meta *ResponseMeta, body []byte) error {
+	if meta == nil || !IsProblemJSON(meta.ContentType) {
+		return nil
+	}
+	problem := &ProblemDetails{}
+	if err := json.Unmarshal(body, problem); err != nil {
+		hr.logError(err, ErrorClassDecode)
+		return wrap(err)
+	}
+	if problem.Status == 0 {
+		problem.Status = meta.StatusCode
+	}
+	hr.logError(problem, ErrorClassProblem)
+	return problem
+}
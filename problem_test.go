@@ -0,0 +1,103 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func problemEndpoint(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeProblemJSON+"; charset=utf-8")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+func TestProblemDetailsFromJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(problemEndpoint(http.StatusForbidden, `{"type":"https://example.com/probs/out-of-credit","title":"You do not have enough credit.","detail":"Your current balance is 30, but that costs 50.","instance":"/account/12345/msgs/abc","balance":30,"accounts":["/account/12345","/account/67890"]}`))
+	defer ts.Close()
+
+	var status statusObject
+	meta, err := New().WithURL(ts.URL).JSONWithMeta(&status)
+	assert.NotNil(err)
+	assert.Equal(http.StatusForbidden, meta.StatusCode)
+
+	var problem *ProblemDetails
+	assert.True(errors.As(err, &problem))
+	assert.Equal("https://example.com/probs/out-of-credit", problem.Type)
+	assert.Equal("You do not have enough credit.", problem.Title)
+	assert.Equal(http.StatusForbidden, problem.Status)
+	assert.Equal("/account/12345/msgs/abc", problem.Instance)
+	assert.Len(problem.Extensions, 2)
+
+	var balance int
+	found, err := problem.Extension("balance", &balance)
+	assert.Nil(err)
+	assert.True(found)
+	assert.Equal(30, balance)
+
+	found, err = problem.Extension("missing", &balance)
+	assert.Nil(err)
+	assert.False(found)
+	assert.Equal(ErrorClassProblem, ClassifyError(problem))
+}
+
+func TestProblemDetailsTerminalMethods(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(problemEndpoint(http.StatusNotFound, `{"title":"Not Found","status":404}`))
+	defer ts.Close()
+
+	var problem *ProblemDetails
+	_, err := New().WithURL(ts.URL).Bytes()
+	assert.True(errors.As(err, &problem))
+	err = New().WithURL(ts.URL).Execute()
+	assert.True(errors.As(err, &problem))
+
+	var errorObject map[string]interface{}
+	_, err = New().WithURL(ts.URL).JSONWithErrorHandler(&statusObject{}, &errorObject)
+	assert.True(errors.As(err, &problem))
+	assert.Equal("Not Found", errorObject["title"])
+}
+
+func TestProblemDetailsEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(problemEndpoint(http.StatusBadRequest, `{"title":"Bad Request"}`))
+	defer ts.Close()
+
+	var class ErrorClass
+	err := New().WithURL(ts.URL).OnError(func(req *Meta, err error) {
+		class = ClassifyError(err)
+	}).Execute()
+	assert.NotNil(err)
+	assert.Equal(ErrorClassProblem, class)
+}
+
+func TestProblemDetailsMarshalJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	problem := ProblemDetails{
+		Title:      "Conflict",
+		Status:     http.StatusConflict,
+		Extensions: map[string]json.RawMessage{"version": json.RawMessage(`3`), "title": json.RawMessage(`"ignored"`)},
+	}
+	contents, err := json.Marshal(problem)
+	assert.Nil(err)
+	assert.Equal(`{"status":409,"title":"Conflict","version":3}`, string(contents))
+}
+
+func TestIsProblemJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(IsProblemJSON("application/problem+json"))
+	assert.True(IsProblemJSON("application/problem+json; charset=utf-8"))
+	assert.False(IsProblemJSON("application/json"))
+	assert.False(IsProblemJSON(""))
+}
@@ -0,0 +1,245 @@
+package request
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+var (
+	// ErrRateLimited is returned by a fail fast rate limiter when no token is available.
+	ErrRateLimited = exception.New("request: rate limit exceeded")
+)
+
+const (
+	// HeaderRateLimitRemaining is the header servers use to report the requests left in the current window.
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	// HeaderRateLimitReset is the header servers use to report when the current window resets.
+	HeaderRateLimitReset = "X-RateLimit-Reset"
+	// HeaderRetryAfter is the header servers use to ask clients to back off.
+	HeaderRetryAfter = "Retry-After"
+)
+
+// NewRateLimiter returns a token bucket rate limiter allowing `rate` requests per second
+// with bursts of up to `burst` requests, per host.
+// The rate must be greater than zero; otherwise every request through the limiter fails with an error.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	rl := &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		keyBy:   RequestKeyHost,
+		buckets: map[string]*tokenBucket{},
+		now:     time.Now,
+	}
+	if !(rate > 0) {
+		rl.err = exception.Newf("request: rate limiter rate must be greater than zero, got %v", rate)
+	}
+	return rl
+}
+
+// RateLimiter limits the rate of requests with a token bucket per host or label.
+// By default requests block until a token is available or the request context is done.
+// A limiter is safe to share between requests and goroutines.
+type RateLimiter struct {
+	sync.Mutex
+
+	rate     float64
+	burst    float64
+	keyBy    RequestKey
+	failFast bool
+	adaptive bool
+
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+	err     error
+}
+
+type tokenBucket struct {
+	tokens       float64
+	last         time.Time
+	pausedUntil  time.Time
+	adaptiveRate float64
+	adaptiveTill time.Time
+}
+
+// WithKeyBy sets what requests share a bucket.
+func (rl *RateLimiter) WithKeyBy(key RequestKey) *RateLimiter {
+	rl.keyBy = key
+	return rl
+}
+
+// WithFailFast makes requests fail with `ErrRateLimited` instead of waiting for a token.
+func (rl *RateLimiter) WithFailFast() *RateLimiter {
+	rl.failFast = true
+	return rl
+}
+
+// WithAdaptive adjusts the bucket from rate limit response headers:
+//   - `Retry-After` pauses the bucket for its duration.
+//   - `X-RateLimit-Remaining` with `X-RateLimit-Reset` slows the bucket to spread the remaining requests
+//     until the reset, or pauses it until the reset if none remain.
+//   - `X-RateLimit-Remaining` of zero without a usable reset empties the bucket, so the next request waits for a token.
+//
+// Other values, including a remaining count without a reset, are ignored.
+func (rl *RateLimiter) WithAdaptive() *RateLimiter {
+	rl.adaptive = true
+	return rl
+}
+
+// Key returns the bucket key for a request, see `RequestKey.Key`.
+// Load balanced and service discovery requests keyed by host get a bucket per backend, like their circuits.
+func (rl *RateLimiter) Key(req *Request) string {
+	return rl.keyBy.Key(req)
+}
+
+// Wait takes a token for the key, waiting for one to become available unless the limiter fails fast.
+func (rl *RateLimiter) Wait(ctx context.Context, key string) error {
+	if rl.err != nil {
+		return rl.err
+	}
+	wait, err := rl.reserve(key)
+	if err != nil || wait <= 0 {
+		return err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		rl.cancel(key)
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (rl *RateLimiter) reserve(key string) (time.Duration, error) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := rl.now()
+	bucket, hasBucket := rl.buckets[key]
+	if !hasBucket {
+		bucket = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = bucket
+	}
+
+	rate := rl.rate
+	if bucket.adaptiveRate > 0 && now.Before(bucket.adaptiveTill) && bucket.adaptiveRate < rate {
+		rate = bucket.adaptiveRate
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * rate
+	if bucket.tokens > rl.burst {
+		bucket.tokens = rl.burst
+	}
+	bucket.last = now
+
+	var wait time.Duration
+	if now.Before(bucket.pausedUntil) {
+		wait = bucket.pausedUntil.Sub(now)
+	}
+	if bucket.tokens < 1 {
+		tokenWait := time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+		if tokenWait > wait {
+			wait = tokenWait
+		}
+	}
+
+	if wait > 0 && rl.failFast {
+		return 0, ErrRateLimited
+	}
+	bucket.tokens--
+	return wait, nil
+}
+
+// cancel returns a reserved token that was not used.
+func (rl *RateLimiter) cancel(key string) {
+	rl.Lock()
+	defer rl.Unlock()
+	if bucket, hasBucket := rl.buckets[key]; hasBucket {
+		bucket.tokens++
+	}
+}
+
+// observe adjusts the bucket for a key from the rate limit headers on a response.
+func (rl *RateLimiter) observe(key string, res *http.Response) {
+	if !rl.adaptive || res == nil {
+		return
+	}
+
+	rl.Lock()
+	defer rl.Unlock()
+	bucket, hasBucket := rl.buckets[key]
+	if !hasBucket {
+		return
+	}
+
+	now := rl.now()
+	if retryAfter, ok := parseRetryAfter(res.Header.Get(HeaderRetryAfter), now); ok {
+		bucket.pausedUntil = now.Add(retryAfter)
+	}
+
+	remaining, err := strconv.Atoi(res.Header.Get(HeaderRateLimitRemaining))
+	if err != nil {
+		return
+	}
+	reset, ok := parseRateLimitReset(res.Header.Get(HeaderRateLimitReset), now)
+	if !ok {
+		if remaining <= 0 && bucket.tokens > 0 {
+			bucket.tokens = 0
+		}
+		return
+	}
+	if remaining <= 0 {
+		if reset.After(bucket.pausedUntil) {
+			bucket.pausedUntil = reset
+		}
+		return
+	}
+	if untilReset := reset.Sub(now).Seconds(); untilReset > 0 {
+		bucket.adaptiveRate = float64(remaining) / untilReset
+		bucket.adaptiveTill = reset
+	}
+}
+
+// parseRetryAfter parses a `Retry-After` header given in seconds or as an http date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if isEmpty(value) {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now), true
+	}
+	return 0, false
+}
+
+// parseRateLimitReset parses a `X-RateLimit-Reset` header given as a unix timestamp or seconds from now.
+func parseRateLimitReset(value string, now time.Time) (time.Time, bool) {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	// values this large can only be unix timestamps.
+	if seconds > 1000000000 {
+		return time.Unix(seconds, 0), true
+	}
+	return now.Add(time.Duration(seconds) * time.Second), true
+}
+
+// WithRateLimiter sets a rate limiter for the request.
+func (hr *Request) WithRateLimiter(limiter *RateLimiter) *Request {
+	hr.rateLimiter = limiter
+	return hr
+}
@@ -0,0 +1,147 @@
+package request
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2017, 01, 01, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(2, 2)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		wait, err := limiter.reserve("host")
+		assert.Nil(err)
+		assert.Equal(time.Duration(0), wait)
+	}
+	wait, err := limiter.reserve("host")
+	assert.Nil(err)
+	assert.Equal(500*time.Millisecond, wait)
+
+	now = now.Add(time.Second)
+	wait, err = limiter.reserve("host")
+	assert.Nil(err)
+	assert.Equal(time.Duration(0), wait)
+
+	wait, err = limiter.reserve("other")
+	assert.Nil(err)
+	assert.Equal(time.Duration(0), wait)
+}
+
+func TestRateLimiterFailFast(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer ts.Close()
+
+	limiter := NewRateLimiter(0.001, 1).WithFailFast()
+	assert.Nil(New().WithURL(ts.URL).WithRateLimiter(limiter).Execute())
+	err := New().WithURL(ts.URL).WithRateLimiter(limiter).Execute()
+	assert.Equal(ErrRateLimited, err)
+	assert.Equal(ErrorClassRateLimited, ClassifyError(err))
+}
+
+func TestRateLimiterWaitRespectsContext(t *testing.T) {
+	assert := assert.New(t)
+
+	limiter := NewRateLimiter(0.001, 1)
+	assert.Nil(limiter.Wait(context.Background(), "host"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, limiter.Wait(ctx, "host"))
+}
+
+func TestRateLimiterAdaptive(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2017, 01, 01, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(100, 1).WithAdaptive()
+	limiter.now = func() time.Time { return now }
+
+	_, err := limiter.reserve("host")
+	assert.Nil(err)
+	res := &http.Response{Header: http.Header{}}
+	res.Header.Set(HeaderRateLimitRemaining, "5")
+	res.Header.Set(HeaderRateLimitReset, "10")
+	limiter.observe("host", res)
+
+	// 5 requests left over 10 seconds slows the bucket to one every two seconds.
+	wait, err := limiter.reserve("host")
+	assert.Nil(err)
+	assert.Equal(2*time.Second, wait)
+
+	res = &http.Response{Header: http.Header{}}
+	res.Header.Set(HeaderRetryAfter, "30")
+	limiter.observe("host", res)
+	now = now.Add(10 * time.Second)
+	wait, err = limiter.reserve("host")
+	assert.Nil(err)
+	assert.Equal(20*time.Second, wait)
+}
+
+func TestRateLimiterAdaptiveRemainingWithoutReset(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2017, 01, 01, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(1, 5).WithAdaptive()
+	limiter.now = func() time.Time { return now }
+
+	_, err := limiter.reserve("host")
+	assert.Nil(err)
+	res := &http.Response{Header: http.Header{}}
+	res.Header.Set(HeaderRateLimitRemaining, "0")
+	limiter.observe("host", res)
+
+	wait, err := limiter.reserve("host")
+	assert.Nil(err)
+	assert.Equal(time.Second, wait)
+}
+
+func TestRateLimiterInvalidRate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NotNil(NewRateLimiter(0, 1).Wait(context.Background(), "host"))
+	assert.NotNil(NewRateLimiter(-1, 1).Wait(context.Background(), "host"))
+}
+
+func TestRateLimiterAfterCircuitBreaker(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(errorMeta(), statusOkObject(), nil)
+	defer ts.Close()
+
+	breaker := NewCircuitBreaker().WithConsecutiveFailures(1).WithCoolDown(time.Minute)
+	limiter := NewRateLimiter(0.001, 2).WithFailFast()
+
+	assert.Nil(New().WithURL(ts.URL).WithCircuitBreaker(breaker).WithRateLimiter(limiter).Execute())
+	for x := 0; x < 3; x++ {
+		assert.Equal(ErrCircuitOpen, New().WithURL(ts.URL).WithCircuitBreaker(breaker).WithRateLimiter(limiter).Execute())
+	}
+	// the rejected requests did not take the second token.
+	assert.Nil(limiter.Wait(context.Background(), New().WithURL(ts.URL).Host))
+}
+
+func TestRateLimiterKeysBalancedHosts(t *testing.T) {
+	assert := assert.New(t)
+
+	first := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer first.Close()
+	second := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer second.Close()
+
+	limiter := NewRateLimiter(0.001, 1).WithFailFast()
+	lb := NewLoadBalancer(hostOf(first.URL), hostOf(second.URL))
+	for x := 0; x < 2; x++ {
+		assert.Nil(New().WithURL("http://service/").WithLoadBalancer(lb).WithRateLimiter(limiter).Execute())
+	}
+	assert.Equal(ErrRateLimited, New().WithURL("http://service/").WithLoadBalancer(lb).WithRateLimiter(limiter).Execute())
+	assert.Nil(limiter.Wait(context.Background(), "service"))
+}
@@ -0,0 +1,75 @@
+package request
+
+import (
+	"net/http"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+const (
+	// DefaultMaxRedirects is the number of redirects followed when a policy does not set `MaxRedirects`.
+	DefaultMaxRedirects = 10
+)
+
+// RedirectPolicy controls how redirects are followed.
+type RedirectPolicy struct {
+	// Never returns the first redirect response instead of following it.
+	Never bool
+	// MaxRedirects is the number of redirects to follow before failing, `DefaultMaxRedirects` if unset.
+	MaxRedirects int
+	// SameHostOnly returns the redirect response instead of following a redirect to another host.
+	SameHostOnly bool
+	// PreserveHeaders are headers copied from the original request to every redirect, including headers
+	// like `Authorization` and `Cookie` that are otherwise dropped when redirected to another domain.
+	PreserveHeaders []string
+}
+
+// CheckRedirect implements `http.Client.CheckRedirect` for the policy.
+func (rp RedirectPolicy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if rp.Never {
+		return http.ErrUseLastResponse
+	}
+
+	maxRedirects := rp.MaxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+	if len(via) > maxRedirects {
+		return exception.Newf("stopped after %d redirects", maxRedirects)
+	}
+
+	original := via[0]
+	if rp.SameHostOnly && req.URL.Host != original.URL.Host {
+		return http.ErrUseLastResponse
+	}
+
+	for _, header := range rp.PreserveHeaders {
+		if values := original.Header[http.CanonicalHeaderKey(header)]; len(values) > 0 {
+			req.Header[http.CanonicalHeaderKey(header)] = values
+		}
+	}
+	return nil
+}
+
+// WithRedirectPolicy sets how redirects are followed.
+func (hr *Request) WithRedirectPolicy(policy RedirectPolicy) *Request {
+	hr.redirectPolicy = &policy
+	return hr
+}
+
+// redirectMetas returns the requests made while following redirects to reach a response, in order,
+// starting with the original request. It is empty if no redirects were followed.
+func redirectMetas(res *http.Response) []*Meta {
+	if res.Request == nil || res.Request.Response == nil {
+		return nil
+	}
+	var redirects []*Meta
+	for req := res.Request; req != nil; {
+		redirects = append([]*Meta{NewMeta(req)}, redirects...)
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+	return redirects
+}
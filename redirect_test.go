@@ -0,0 +1,91 @@
+package request
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func redirectingEndpoint(target string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, target, http.StatusFound)
+		default:
+			writeJSON(w, okMeta(), statusOkObject())
+		}
+	}
+}
+
+func TestRedirectChain(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(redirectingEndpoint("/c"))
+	defer ts.Close()
+
+	var status statusObject
+	meta, err := New().WithURL(ts.URL + "/a").JSONWithMeta(&status)
+	assert.Nil(err)
+	assert.Equal("ok!", status.Status)
+	assert.Len(meta.Redirects, 3)
+	assert.Equal("/a", meta.Redirects[0].URL.Path)
+	assert.Equal("/b", meta.Redirects[1].URL.Path)
+	assert.Equal("/c", meta.Redirects[2].URL.Path)
+}
+
+func TestRedirectPolicyNever(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(redirectingEndpoint("/c"))
+	defer ts.Close()
+
+	meta, err := New().WithURL(ts.URL + "/a").WithRedirectPolicy(RedirectPolicy{Never: true}).ExecuteWithMeta()
+	assert.Nil(err)
+	assert.Equal(http.StatusFound, meta.StatusCode)
+	assert.Equal("/b", meta.Headers.Get("Location"))
+	assert.Empty(meta.Redirects)
+}
+
+func TestRedirectPolicyMaxRedirects(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getMockServer(redirectingEndpoint("/c"))
+	defer ts.Close()
+
+	_, err := New().WithURL(ts.URL + "/a").WithRedirectPolicy(RedirectPolicy{MaxRedirects: 1}).ExecuteWithMeta()
+	assert.NotNil(err)
+}
+
+func TestRedirectPolicySameHostAndPreserveHeaders(t *testing.T) {
+	assert := assert.New(t)
+
+	var authorization string
+	other := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		writeJSON(w, okMeta(), statusOkObject())
+	})
+	defer other.Close()
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1) + "/c"
+
+	ts := getMockServer(redirectingEndpoint(otherURL))
+	defer ts.Close()
+
+	meta, err := New().WithURL(ts.URL+"/a").WithBasicAuth("user", "pass").WithRedirectPolicy(RedirectPolicy{SameHostOnly: true}).ExecuteWithMeta()
+	assert.Nil(err)
+	assert.Equal(http.StatusFound, meta.StatusCode)
+	assert.Len(meta.Redirects, 2)
+
+	_, err = New().WithURL(ts.URL+"/a").WithBasicAuth("user", "pass").ExecuteWithMeta()
+	assert.Nil(err)
+	assert.Equal("", authorization)
+
+	meta, err = New().WithURL(ts.URL+"/a").WithBasicAuth("user", "pass").WithRedirectPolicy(RedirectPolicy{PreserveHeaders: []string{"authorization"}}).ExecuteWithMeta()
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, meta.StatusCode)
+	assert.NotEmpty(authorization)
+	assert.Len(meta.Redirects, 3)
+}
@@ -2,7 +2,9 @@ package request
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -49,6 +51,9 @@ type Request struct {
 	Host   string
 	Path   string
 
+	PathTemplate string
+	PathParams   map[string]string
+
 	QueryString url.Values
 
 	Cookies []*http.Cookie
@@ -61,29 +66,65 @@ type Request struct {
 	PostData    url.Values
 	Body        []byte
 
-	Timeout time.Duration
+	Timeout               time.Duration
+	ConnectTimeout        time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	BodyReadTimeout       time.Duration
+
+	TLSClientCertPath   string
+	TLSClientKeyPath    string
+	TLSClientCert       *tls.Certificate
+	TLSClientCertReload bool
+	TLSSkipVerify       bool
+	TLSRootCAs          *x509.CertPool
+	TLSPinnedPublicKeys [][]byte
+	TLSMinVersion       uint16
+	TLSServerName       string
 
-	TLSClientCertPath string
-	TLSClientKeyPath  string
-	TLSSkipVerify     bool
+	ProxyURL    *url.URL
+	ProxyBypass []string
+
+	UnixSocketPath string
+	HostOverrides  map[string]string
 
 	KeepAlive        bool
 	KeepAliveTimeout time.Duration
 	Label            string
 
 	logger         *logger.Agent
+	ctx            context.Context
 	state          interface{}
 	postedFiles    []PostedFile
 	responseBuffer Buffer
 	requestStart   time.Time
+	attempt        int
+	hedgeDelay     time.Duration
+	hedgeMaxExtra  int
+	hedgeAttempt   int
 
 	err error
 
 	transport                       *http.Transport
+	certificateProvider             *CertificateProvider
+	redirectPolicy                  *RedirectPolicy
+	circuitBreaker                  *CircuitBreaker
+	rateLimiter                     *RateLimiter
+	dedupGroup                      *DedupGroup
+	loadBalancer                    *LoadBalancer
+	serviceDiscovery                *ServiceDiscovery
+	resolvedHost                    string
+	dialer                          DialFunc
+	dnsCache                        *DNSCache
+	responseSchema                  *Schema
+	responseSchemaLogOnly           bool
+	graphQLOperationName            string
+	graphQLPersistedQuery           bool
 	createTransportHandler          CreateTransportHandler
 	incomingResponseHandler         ResponseHandler
 	statefulIncomingResponseHandler StatefulResponseHandler
 	outgoingRequestHandler          OutgoingRequestHandler
+	errorHandler                    ErrorHandler
 	mockProvider                    MockedResponseProvider
 }
 
@@ -111,6 +152,26 @@ func (hr *Request) OnRequest(hook OutgoingRequestHandler) *Request {
 	return hr
 }
 
+// OnError configures an event receiver for failed requests.
+func (hr *Request) OnError(hook ErrorHandler) *Request {
+	hr.errorHandler = hook
+	return hr
+}
+
+// WithContext sets a context for the request, used for cancellation and deadlines.
+func (hr *Request) WithContext(ctx context.Context) *Request {
+	hr.ctx = ctx
+	return hr
+}
+
+// Context returns the request context, or `context.Background()` if one is not set.
+func (hr *Request) Context() context.Context {
+	if hr.ctx != nil {
+		return hr.ctx
+	}
+	return context.Background()
+}
+
 // WithState adds a state object to the request for later usage.
 func (hr *Request) WithState(state interface{}) *Request {
 	hr.state = state
@@ -152,6 +213,22 @@ func (hr *Request) WithTransport(transport *http.Transport) *Request {
 	return hr
 }
 
+// WithDialer sets the function the request transport uses to open connections.
+func (hr *Request) WithDialer(dialer DialFunc) *Request {
+	hr.dialer = dialer
+	return hr
+}
+
+// WithUnixSocket connects to a unix domain socket instead of the request host.
+// The host is still used for the url and `Host` header, so it can be a logical name.
+func (hr *Request) WithUnixSocket(socketPath string) *Request {
+	hr.UnixSocketPath = socketPath
+	if isEmpty(hr.Host) {
+		hr.Host = "localhost"
+	}
+	return hr
+}
+
 // WithKeepAlives sets if the request should use the `Connection=keep-alive` header or not.
 func (hr *Request) WithKeepAlives() *Request {
 	hr.KeepAlive = true
@@ -186,18 +263,21 @@ func (hr *Request) WithHost(host string) *Request {
 // WithPath sets the path component of the host url..
 func (hr *Request) WithPath(path string) *Request {
 	hr.Path = path
+	hr.PathTemplate = ""
 	return hr
 }
 
 // WithPathf sets the path component of the host url by the format and arguments.
 func (hr *Request) WithPathf(format string, args ...interface{}) *Request {
 	hr.Path = fmt.Sprintf(format, args...)
+	hr.PathTemplate = ""
 	return hr
 }
 
 // WithCombinedPath sets the path component of the host url by combining the input path segments.
 func (hr *Request) WithCombinedPath(components ...string) *Request {
 	hr.Path = util.String.CombinePathComponents(components...)
+	hr.PathTemplate = ""
 	return hr
 }
 
@@ -212,6 +292,7 @@ func (hr *Request) WithURL(urlString string) *Request {
 	hr.Scheme = workingURL.Scheme
 	hr.Host = workingURL.Host
 	hr.Path = workingURL.Path
+	hr.PathTemplate = ""
 	queryValues, err := url.ParseQuery(workingURL.RawQuery)
 	if err != nil {
 		hr.err = err
@@ -283,8 +364,9 @@ func (hr *Request) WithBasicAuth(username, password string) *Request {
 	return hr
 }
 
-// WithTimeout sets a timeout for the request.
-// Remarks: This timeout is enforced on client connect, not on request read + response.
+// WithTimeout sets the overall timeout for the request, including reading the response body.
+// Remarks: It is also used as the connect timeout unless `WithConnectTimeout` is set; see
+// `WithTLSHandshakeTimeout`, `WithResponseHeaderTimeout` and `WithBodyReadTimeout` for the other phases.
 func (hr *Request) WithTimeout(timeout time.Duration) *Request {
 	hr.Timeout = timeout
 	return hr
@@ -375,8 +457,21 @@ func (hr *Request) WithPostBody(body []byte) *Request {
 }
 
 // URL returns the currently formatted request target url.
+// While the request is being sent and its response logged it has the host chosen by service discovery or the load balancer.
 func (hr *Request) URL() *url.URL {
-	workingURL := &url.URL{Scheme: hr.Scheme, Host: hr.Host, Path: hr.Path}
+	workingURL := hr.configuredURL()
+	if !isEmpty(hr.resolvedHost) {
+		workingURL.Host = hr.resolvedHost
+	}
+	return workingURL
+}
+
+// configuredURL returns the request url with the host it was configured with.
+func (hr *Request) configuredURL() *url.URL {
+	workingURL := &url.URL{Scheme: hr.urlScheme(), Host: hr.Host, Path: hr.Path}
+	if !isEmpty(hr.PathTemplate) {
+		workingURL.Path, workingURL.RawPath, _ = hr.expandPath()
+	}
 	workingURL.RawQuery = hr.QueryString.Encode()
 	return workingURL
 }
@@ -384,11 +479,15 @@ func (hr *Request) URL() *url.URL {
 // Meta returns the request as a HTTPRequestMeta.
 func (hr Request) Meta() *Meta {
 	return &Meta{
-		StartTime: hr.requestStart,
-		Verb:      hr.Verb,
-		URL:       hr.URL(),
-		Body:      hr.PostBody(),
-		Headers:   hr.Headers(),
+		StartTime:    hr.requestStart,
+		Label:        hr.Label,
+		Attempt:      hr.attempt,
+		Verb:         hr.Verb,
+		URL:          hr.URL(),
+		PathTemplate: hr.PathTemplate,
+		Body:         hr.PostBody(),
+		Headers:      hr.Headers(),
+		Cookies:      hr.Cookies,
 	}
 }
 
@@ -424,6 +523,11 @@ func (hr *Request) Request() (*http.Request, error) {
 	if hr.err != nil {
 		return nil, hr.err
 	}
+	if !isEmpty(hr.PathTemplate) {
+		if _, _, err := hr.expandPath(); err != nil {
+			return nil, err
+		}
+	}
 
 	workingURL := hr.URL()
 
@@ -433,7 +537,10 @@ func (hr *Request) Request() (*http.Request, error) {
 
 	req, err := http.NewRequest(hr.Verb, workingURL.String(), bytes.NewBuffer(hr.PostBody()))
 	if err != nil {
-		return nil, exception.Wrap(err)
+		return nil, wrap(err)
+	}
+	if hr.ctx != nil {
+		req = req.WithContext(hr.ctx)
 	}
 
 	if !isEmpty(hr.BasicAuthUsername) {
@@ -458,8 +565,39 @@ func (hr *Request) Request() (*http.Request, error) {
 
 // Response makes the actual request but returns the underlying http.Response object.
 func (hr *Request) Response() (*http.Response, error) {
+	defer hr.clearResolvedHost()
+	return hr.send()
+}
+
+// send makes the request, leaving the host chosen by service discovery or the load balancer set
+// so the response can be logged against it; callers clear it with `clearResolvedHost` when they are done.
+func (hr *Request) send() (*http.Response, error) {
+	targets, err := hr.resolveService()
+	if err != nil {
+		hr.logError(err, ClassifyError(err))
+		return nil, wrap(err)
+	}
+	var res *http.Response
+	if hr.loadBalancer != nil {
+		res, err = hr.balancedResponse()
+	} else if len(targets) > 0 {
+		res, err = hr.discoveredResponse(targets)
+	} else {
+		res, err = hr.roundTrip()
+	}
+	return res, wrap(err)
+}
+
+// clearResolvedHost resets the host chosen for a call, which only applies to that call.
+func (hr *Request) clearResolvedHost() {
+	hr.resolvedHost = ""
+}
+
+// roundTrip makes a single attempt of the request.
+func (hr *Request) roundTrip() (*http.Response, error) {
 	req, err := hr.Request()
 	if err != nil {
+		hr.logError(err, ClassifyError(err))
 		return nil, err
 	}
 
@@ -468,15 +606,36 @@ func (hr *Request) Response() (*http.Response, error) {
 	if hr.mockProvider != nil {
 		mockedRes := hr.mockProvider(hr)
 		if mockedRes != nil {
+			if mockedRes.Err != nil {
+				hr.logError(mockedRes.Err, ClassifyError(mockedRes.Err))
+			}
 			return mockedRes.Response(), mockedRes.Err
 		}
 	}
 
+	if hr.canDedup() {
+		var madeCall bool
+		res, err := hr.dedupGroup.Do(hr.Context(), hr.DedupKey(), func() (*http.Response, error) {
+			madeCall = true
+			return hr.response(req)
+		})
+		// the request that made the call has logged its own error.
+		if err != nil && !madeCall {
+			hr.logError(err, ClassifyError(err))
+		}
+		return res, err
+	}
+	return hr.response(req)
+}
+
+// response sends the request over the network.
+func (hr *Request) response(req *http.Request) (*http.Response, error) {
 	client := &http.Client{}
 	if hr.requiresCustomTransport() {
 		transport, transportErr := hr.getTransport()
 		if transportErr != nil {
-			return nil, exception.Wrap(transportErr)
+			hr.logError(transportErr, ClassifyError(transportErr))
+			return nil, transportErr
 		}
 		client.Transport = transport
 	}
@@ -485,41 +644,103 @@ func (hr *Request) Response() (*http.Response, error) {
 		client.Timeout = hr.Timeout
 	}
 
-	res, resErr := client.Do(req)
-	return res, exception.Wrap(resErr)
+	if hr.redirectPolicy != nil {
+		client.CheckRedirect = hr.redirectPolicy.CheckRedirect
+	}
+
+	// the circuit breaker goes first so requests it rejects do not use up rate limit tokens.
+	var circuitKey string
+	if hr.circuitBreaker != nil {
+		circuitKey = hr.circuitBreaker.Key(hr)
+		if err := hr.circuitBreaker.allow(circuitKey, hr.logger); err != nil {
+			hr.logError(err, ErrorClassCircuitOpen)
+			return nil, err
+		}
+	}
+
+	var rateLimitKey string
+	if hr.rateLimiter != nil {
+		rateLimitKey = hr.rateLimiter.Key(hr)
+		if err := hr.rateLimiter.Wait(hr.Context(), rateLimitKey); err != nil {
+			if hr.circuitBreaker != nil {
+				hr.circuitBreaker.release(circuitKey)
+			}
+			hr.logError(err, ClassifyError(err))
+			return nil, err
+		}
+	}
+
+	var res *http.Response
+	var resErr error
+	if hr.isHedged() {
+		res, resErr = hr.doHedged(client)
+	} else {
+		var phases *timeoutPhases
+		req, phases = traceTimeoutPhases(req)
+		res, resErr = client.Do(req)
+		if resErr != nil {
+			resErr = hr.timeoutError(resErr, phases.current())
+		}
+	}
+	if hr.circuitBreaker != nil {
+		if resErr != nil && hr.Context().Err() != nil {
+			// the caller gave up on the request or ran out of time, which says nothing about the health of the host.
+			hr.circuitBreaker.release(circuitKey)
+		} else {
+			hr.circuitBreaker.record(circuitKey, isCircuitBreakerFailure(res, resErr), hr.logger)
+		}
+	}
+	if hr.rateLimiter != nil {
+		hr.rateLimiter.observe(rateLimitKey, res)
+	}
+	if resErr != nil {
+		hr.logError(resErr, ClassifyError(resErr))
+		return res, resErr
+	}
+	res.Body = hr.newTimeoutBody(res.Body)
+	return res, nil
 }
 
 // Execute makes the request but does not read the response.
 func (hr *Request) Execute() error {
 	_, err := hr.ExecuteWithMeta()
-	return exception.Wrap(err)
+	return wrap(err)
 }
 
 // ExecuteWithMeta makes the request and returns the meta of the response.
 func (hr *Request) ExecuteWithMeta() (*ResponseMeta, error) {
-	res, err := hr.Response()
+	defer hr.clearResolvedHost()
+	res, err := hr.send()
 	if err != nil {
-		return nil, exception.Wrap(err)
+		return nil, wrap(err)
 	}
-	meta := NewResponseMeta(res)
+	meta := hr.newResponseMeta(res)
 	if res != nil && res.Body != nil {
 		defer res.Body.Close()
 		if hr.responseBuffer != nil {
 			contentLength, err := hr.responseBuffer.ReadFrom(res.Body)
 			if err != nil {
-				return nil, exception.Wrap(err)
+				hr.logError(err, ClassifyError(err))
+				return nil, wrap(err)
 			}
 			meta.ContentLength = contentLength
 			if hr.incomingResponseHandler != nil {
 				hr.logResponse(meta, hr.responseBuffer.Bytes(), hr.state)
 			}
+			if err := hr.problemError(meta, hr.responseBuffer.Bytes()); err != nil {
+				return meta, err
+			}
 		} else {
 			contents, err := ioutil.ReadAll(res.Body)
 			if err != nil {
-				return nil, exception.Wrap(err)
+				hr.logError(err, ClassifyError(err))
+				return nil, wrap(err)
 			}
 			meta.ContentLength = int64(len(contents))
 			hr.logResponse(meta, contents, hr.state)
+			if err := hr.problemError(meta, contents); err != nil {
+				return meta, err
+			}
 		}
 	}
 
@@ -528,21 +749,23 @@ func (hr *Request) ExecuteWithMeta() (*ResponseMeta, error) {
 
 // BytesWithMeta fetches the response as bytes with meta.
 func (hr *Request) BytesWithMeta() ([]byte, *ResponseMeta, error) {
-	res, err := hr.Response()
-	resMeta := NewResponseMeta(res)
+	defer hr.clearResolvedHost()
+	res, err := hr.send()
+	resMeta := hr.newResponseMeta(res)
 	if err != nil {
-		return nil, resMeta, exception.Wrap(err)
+		return nil, resMeta, wrap(err)
 	}
 	defer res.Body.Close()
 
 	bytes, readErr := ioutil.ReadAll(res.Body)
 	if readErr != nil {
-		return nil, resMeta, exception.Wrap(readErr)
+		hr.logError(readErr, ClassifyError(readErr))
+		return nil, resMeta, wrap(readErr)
 	}
 
 	resMeta.ContentLength = int64(len(bytes))
 	hr.logResponse(resMeta, bytes, hr.state)
-	return bytes, resMeta, nil
+	return bytes, resMeta, hr.problemError(resMeta, bytes)
 }
 
 // Bytes fetches the response as bytes.
@@ -609,10 +832,16 @@ func (hr *Request) Deserialized(deserialize Deserializer) (*ResponseMeta, error)
 }
 
 func (hr *Request) requiresCustomTransport() bool {
-	return (!isEmpty(hr.TLSClientCertPath) && !isEmpty(hr.TLSClientKeyPath)) ||
+	return hr.requiresCustomTLS() ||
 		hr.transport != nil ||
 		hr.createTransportHandler != nil ||
-		hr.TLSSkipVerify
+		hr.requiresCustomTimeouts() ||
+		hr.ProxyURL != nil ||
+		len(hr.ProxyBypass) > 0 ||
+		hr.dialer != nil ||
+		hr.dnsCache != nil ||
+		len(hr.HostOverrides) > 0 ||
+		!isEmpty(hr.UnixSocketPath)
 }
 
 func (hr *Request) getTransport() (*http.Transport, error) {
@@ -627,12 +856,13 @@ func (hr *Request) Transport() (*http.Transport, error) {
 	transport := &http.Transport{
 		DisableCompression: false,
 		DisableKeepAlives:  !hr.KeepAlive,
+		Proxy:              hr.ProxyFunc(),
+
+		TLSHandshakeTimeout:   hr.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: hr.ResponseHeaderTimeout,
 	}
 
 	dialer := &net.Dialer{}
-	if hr.Timeout != time.Duration(0) {
-		dialer.Timeout = hr.Timeout
-	}
 
 	if hr.KeepAlive {
 		if hr.KeepAliveTimeout != time.Duration(0) {
@@ -642,25 +872,29 @@ func (hr *Request) Transport() (*http.Transport, error) {
 		}
 	}
 
-	transport.Dial = dialer.Dial
-
-	if !isEmpty(hr.TLSClientCertPath) && !isEmpty(hr.TLSClientKeyPath) {
-		cert, err := tls.LoadX509KeyPair(hr.TLSClientCertPath, hr.TLSClientKeyPath)
-		if err != nil {
-			return nil, exception.Wrap(err)
-		}
-		tlsConfig := &tls.Config{
-			InsecureSkipVerify: hr.TLSSkipVerify,
-			Certificates:       []tls.Certificate{cert},
+	dial := DialFunc(dialer.DialContext)
+	if hr.dialer != nil {
+		dial = hr.dialer
+	}
+	// the connect timeout applies to each address the resolving dial tries, not to all of them together.
+	dial = hr.resolvingDial(dialWithTimeout(dial, hr.connectTimeout()))
+	if !isEmpty(hr.UnixSocketPath) {
+		socketPath := hr.UnixSocketPath
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dial(ctx, "unix", socketPath)
 		}
-		transport.TLSClientConfig = tlsConfig
+		// the socket is the destination, so the host is never sent to a proxy.
+		transport.Proxy = nil
 	} else {
-		tlsConfig := &tls.Config{
-			InsecureSkipVerify: hr.TLSSkipVerify,
-		}
-		transport.TLSClientConfig = tlsConfig
+		transport.DialContext = dial
 	}
 
+	tlsConfig, err := hr.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
 	if hr.createTransportHandler != nil {
 		hr.createTransportHandler(hr.URL(), transport)
 	}
@@ -669,43 +903,59 @@ func (hr *Request) Transport() (*http.Transport, error) {
 }
 
 func (hr *Request) deserialize(handler Deserializer) (*ResponseMeta, error) {
-	res, err := hr.Response()
-	meta := NewResponseMeta(res)
+	defer hr.clearResolvedHost()
+	res, err := hr.send()
+	meta := hr.newResponseMeta(res)
 
 	if err != nil {
-		return meta, exception.Wrap(err)
+		return meta, wrap(err)
 	}
 	defer res.Body.Close()
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return meta, exception.Wrap(err)
+		hr.logError(err, ClassifyError(err))
+		return meta, wrap(err)
 	}
 
 	meta.ContentLength = int64(len(body))
 	hr.logResponse(meta, body, hr.state)
+	if err := hr.problemError(meta, body); err != nil {
+		return meta, err
+	}
+	if err := hr.validateResponse(meta, body); err != nil {
+		return meta, err
+	}
 	if handler != nil {
 		err = handler(body)
+		if err != nil {
+			hr.logError(err, ErrorClassDecode)
+		}
 	}
-	return meta, exception.Wrap(err)
+	return meta, wrap(err)
 }
 
 func (hr *Request) deserializeWithError(okHandler Deserializer, errorHandler Deserializer) (*ResponseMeta, error) {
-	res, err := hr.Response()
-	meta := NewResponseMeta(res)
+	defer hr.clearResolvedHost()
+	res, err := hr.send()
+	meta := hr.newResponseMeta(res)
 
 	if err != nil {
-		return meta, exception.Wrap(err)
+		return meta, wrap(err)
 	}
 	defer res.Body.Close()
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return meta, exception.Wrap(err)
+		hr.logError(err, ClassifyError(err))
+		return meta, wrap(err)
 	}
 
 	meta.ContentLength = int64(len(body))
 	hr.logResponse(meta, body, hr.state)
+	if err := hr.validateResponse(meta, body); err != nil {
+		return meta, err
+	}
 	if res.StatusCode == http.StatusOK {
 		if okHandler != nil {
 			err = okHandler(body)
@@ -713,11 +963,23 @@ func (hr *Request) deserializeWithError(okHandler Deserializer, errorHandler Des
 	} else if errorHandler != nil {
 		err = errorHandler(body)
 	}
-	return meta, exception.Wrap(err)
+	if err != nil {
+		hr.logError(err, ErrorClassDecode)
+		return meta, wrap(err)
+	}
+	return meta, hr.problemError(meta, body)
+}
+
+func (hr *Request) newResponseMeta(res *http.Response) *ResponseMeta {
+	meta := NewResponseMeta(res)
+	meta.HedgeAttempt = hr.hedgeAttempt
+	return meta
 }
 
 func (hr *Request) logRequest() {
 	hr.requestStart = time.Now().UTC()
+	hr.attempt++
+	hr.hedgeAttempt = 0
 
 	meta := hr.Meta()
 	if hr.outgoingRequestHandler != nil {
@@ -742,6 +1004,60 @@ func (hr *Request) logResponse(resMeta *ResponseMeta, responseBody []byte, state
 	}
 }
 
+func (hr *Request) logError(err error, class ErrorClass) {
+	if hr.errorHandler != nil {
+		hr.errorHandler(hr.Meta(), err)
+	}
+
+	if hr.logger != nil {
+		hr.logger.OnEvent(EventError, hr.Meta(), err, class)
+	}
+}
+
+// clone returns a copy of the request that can be changed and sent without affecting the original.
+// Remarks: posted file readers and the shared components, like the transport and load balancer, are not copied.
+func (hr *Request) clone() *Request {
+	cloned := *hr
+	cloned.Header = cloneHeader(hr.Header)
+	cloned.QueryString = url.Values(cloneHeader(http.Header(hr.QueryString)))
+	cloned.PostData = url.Values(cloneHeader(http.Header(hr.PostData)))
+	cloned.PathParams = cloneStringMap(hr.PathParams)
+	cloned.HostOverrides = cloneStringMap(hr.HostOverrides)
+	if hr.Cookies != nil {
+		cloned.Cookies = make([]*http.Cookie, len(hr.Cookies))
+		for index, cookie := range hr.Cookies {
+			copied := *cookie
+			cloned.Cookies[index] = &copied
+		}
+	}
+	if hr.Body != nil {
+		cloned.Body = append([]byte(nil), hr.Body...)
+	}
+	if hr.ProxyBypass != nil {
+		cloned.ProxyBypass = append([]string(nil), hr.ProxyBypass...)
+	}
+	if hr.TLSPinnedPublicKeys != nil {
+		cloned.TLSPinnedPublicKeys = append([][]byte(nil), hr.TLSPinnedPublicKeys...)
+	}
+	if hr.postedFiles != nil {
+		cloned.postedFiles = append([]PostedFile(nil), hr.postedFiles...)
+	}
+	cloned.resolvedHost = ""
+	cloned.hedgeAttempt = 0
+	return &cloned
+}
+
+func cloneStringMap(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	cloned := make(map[string]string, len(values))
+	for key, value := range values {
+		cloned[key] = value
+	}
+	return cloned
+}
+
 // Hash / Mock Utility Functions
 
 // Hash returns a hashcode for a request.
@@ -753,7 +1069,7 @@ func (hr *Request) Hash() uint32 {
 	buffer := bytes.NewBuffer(nil)
 	buffer.WriteString(hr.Verb)
 	buffer.WriteRune('|')
-	buffer.WriteString(hr.URL().String())
+	buffer.WriteString(hr.configuredURL().String())
 
 	h := fnv.New32a()
 	h.Write(buffer.Bytes())
@@ -770,7 +1086,7 @@ func (hr *Request) Equals(other *Request) bool {
 		return false
 	}
 
-	if hr.URL().String() != other.URL().String() {
+	if hr.configuredURL().String() != other.configuredURL().String() {
 		return false
 	}
 
@@ -836,6 +1152,20 @@ func serializeXMLToReader(object interface{}) (io.Reader, error) {
 	return buf, err
 }
 
+// typedError is implemented by errors callers are expected to inspect with `errors.As`.
+type typedError interface {
+	error
+	isTyped()
+}
+
+// wrap adds a stack trace to an error like `exception.Wrap`, but passes typed errors through unchanged.
+func wrap(err error) error {
+	if typed, isTyped := err.(typedError); isTyped {
+		return typed
+	}
+	return exception.Wrap(err)
+}
+
 func isEmpty(str string) bool {
 	return len(str) == 0
 }
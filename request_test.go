@@ -349,3 +349,27 @@ func TestOnRequestHook(t *testing.T) {
 	assert.Nil(err)
 	assert.True(called)
 }
+
+func TestClone(t *testing.T) {
+	assert := assert.New(t)
+
+	original := New().WithURL("http://localhost/api?foo=bar").
+		WithHeader("X-Tenant", "a").
+		WithCookie(&http.Cookie{Name: "session", Value: "a"}).
+		WithHostOverride("localhost", "10.0.0.1").
+		WithProxyBypass("internal").
+		WithPostedFile("file", "a.txt", nil)
+
+	cloned := original.clone()
+	cloned.WithHeader("X-Tenant", "b").WithQueryString("foo", "baz").WithHostOverride("localhost", "10.0.0.2")
+	cloned.Cookies[0].Value = "b"
+	cloned.ProxyBypass[0] = "other"
+	cloned.postedFiles[0].FileName = "b.txt"
+
+	assert.Equal("a", original.Header.Get("X-Tenant"))
+	assert.Equal([]string{"bar"}, original.QueryString["foo"])
+	assert.Equal("a", original.Cookies[0].Value)
+	assert.Equal("10.0.0.1", original.HostOverrides["localhost"])
+	assert.Equal([]string{"internal"}, original.ProxyBypass)
+	assert.Equal("a.txt", original.postedFiles[0].FileName)
+}
@@ -0,0 +1,145 @@
+package request
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+// IPResolver looks up the addresses of a host; it is implemented by `*net.Resolver`.
+type IPResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// NewDNSCache returns a new dns cache that keeps lookups for `ttl`.
+func NewDNSCache(ttl time.Duration) *DNSCache {
+	return &DNSCache{
+		resolver: net.DefaultResolver,
+		ttl:      ttl,
+		entries:  map[string]dnsCacheEntry{},
+		now:      time.Now,
+	}
+}
+
+// DNSCache caches host lookups for the dialer so new transports do not re-resolve every host.
+// It is safe to share between requests and goroutines.
+type DNSCache struct {
+	sync.Mutex
+
+	resolver IPResolver
+	ttl      time.Duration
+	entries  map[string]dnsCacheEntry
+	now      func() time.Time
+}
+
+type dnsCacheEntry struct {
+	addrs   []net.IPAddr
+	expires time.Time
+}
+
+// WithResolver sets the resolver used for lookups.
+func (dc *DNSCache) WithResolver(resolver IPResolver) *DNSCache {
+	dc.Lock()
+	dc.resolver = resolver
+	dc.Unlock()
+	return dc
+}
+
+// LookupIPAddr returns the addresses for a host from the cache, looking them up if they are missing or expired.
+func (dc *DNSCache) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	dc.Lock()
+	entry, cached := dc.entries[host]
+	resolver := dc.resolver
+	dc.Unlock()
+	if cached && dc.now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	dc.Lock()
+	dc.entries[host] = dnsCacheEntry{addrs: addrs, expires: dc.now().Add(dc.ttl)}
+	dc.Unlock()
+	return addrs, nil
+}
+
+// WithDNSCache resolves hosts for the request through a dns cache.
+func (hr *Request) WithDNSCache(cache *DNSCache) *Request {
+	hr.dnsCache = cache
+	return hr
+}
+
+// WithHostOverride connects to `ip` instead of resolving `host`, like `curl --resolve`.
+// The host can include a port to only override that port. The url, `Host` header and tls server name
+// are unchanged, so the request still looks like it was sent to `host`.
+func (hr *Request) WithHostOverride(host, ip string) *Request {
+	if net.ParseIP(ip) == nil {
+		hr.err = exception.Newf("invalid host override address: %q", ip)
+		return hr
+	}
+	if hr.HostOverrides == nil {
+		hr.HostOverrides = map[string]string{}
+	}
+	hr.HostOverrides[strings.ToLower(host)] = ip
+	return hr
+}
+
+// resolvingDial wraps a dial function to apply host overrides and the dns cache to tcp connections.
+func (hr *Request) resolvingDial(dial DialFunc) DialFunc {
+	if len(hr.HostOverrides) == 0 && hr.dnsCache == nil {
+		return dial
+	}
+
+	overrides := map[string]string{}
+	for host, ip := range hr.HostOverrides {
+		overrides[host] = ip
+	}
+	cache := hr.dnsCache
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if !strings.HasPrefix(network, "tcp") {
+			return dial(ctx, network, addr)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return dial(ctx, network, addr)
+		}
+
+		if ip, hasOverride := overrides[strings.ToLower(addr)]; hasOverride {
+			return dial(ctx, network, net.JoinHostPort(ip, port))
+		}
+		if ip, hasOverride := overrides[strings.ToLower(host)]; hasOverride {
+			return dial(ctx, network, net.JoinHostPort(ip, port))
+		}
+		if cache == nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		addrs, err := cache.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, ipAddr := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(ipAddr.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if lastErr == nil {
+			lastErr = &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+		}
+		return nil, lastErr
+	}
+}
@@ -0,0 +1,151 @@
+package request
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+type fakeIPResolver struct {
+	addrs   map[string][]net.IPAddr
+	lookups int
+}
+
+func (fr *fakeIPResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	fr.lookups++
+	if addrs, hasAddrs := fr.addrs[host]; hasAddrs {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestWithHostOverride(t *testing.T) {
+	assert := assert.New(t)
+
+	var host string
+	ts := getMockServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		writeJSON(w, okMeta(), statusOkObject())
+	}))
+	defer ts.Close()
+
+	_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	assert.Nil(err)
+
+	var status statusObject
+	err = New().WithURL("http://canary.service.invalid:"+port+"/").
+		WithHostOverride("canary.service.invalid", "127.0.0.1").
+		JSON(&status)
+	assert.Nil(err)
+	assert.Equal("ok!", status.Status)
+	assert.Equal("canary.service.invalid:"+port, host)
+}
+
+func TestWithHostOverrideInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := New().WithURL("http://foo.invalid/").WithHostOverride("foo.invalid", "not-an-ip").Response()
+	assert.NotNil(err)
+}
+
+func TestWithHostOverrideCurl(t *testing.T) {
+	assert := assert.New(t)
+
+	curl, err := New().AsGet().WithURL("https://api.test.com/foo").
+		WithHostOverride("api.test.com", "10.0.0.1").
+		WithHostOverride("other.test.com:8443", "::1").
+		Curl()
+	assert.Nil(err)
+	assert.True(strings.Contains(curl, "--resolve 'api.test.com:443:10.0.0.1'"), curl)
+	assert.True(strings.Contains(curl, "--resolve 'other.test.com:8443:[::1]'"), curl)
+}
+
+func TestDNSCache(t *testing.T) {
+	assert := assert.New(t)
+
+	resolver := &fakeIPResolver{
+		addrs: map[string][]net.IPAddr{
+			"cached.service.invalid": {{IP: net.ParseIP("127.0.0.1")}},
+		},
+	}
+	now := time.Date(2017, 01, 01, 0, 0, 0, 0, time.UTC)
+	cache := NewDNSCache(time.Minute).WithResolver(resolver)
+	cache.now = func() time.Time { return now }
+
+	addrs, err := cache.LookupIPAddr(context.Background(), "cached.service.invalid")
+	assert.Nil(err)
+	assert.Len(addrs, 1)
+	_, err = cache.LookupIPAddr(context.Background(), "cached.service.invalid")
+	assert.Nil(err)
+	assert.Equal(1, resolver.lookups)
+
+	now = now.Add(2 * time.Minute)
+	_, err = cache.LookupIPAddr(context.Background(), "cached.service.invalid")
+	assert.Nil(err)
+	assert.Equal(2, resolver.lookups)
+
+	_, err = cache.LookupIPAddr(context.Background(), "missing.service.invalid")
+	assert.NotNil(err)
+}
+
+func TestWithDNSCache(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer ts.Close()
+
+	_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	assert.Nil(err)
+
+	resolver := &fakeIPResolver{
+		addrs: map[string][]net.IPAddr{
+			"cached.service.invalid": {{IP: net.ParseIP("127.0.0.1")}},
+		},
+	}
+	cache := NewDNSCache(time.Minute).WithResolver(resolver)
+
+	for x := 0; x < 2; x++ {
+		var status statusObject
+		err = New().WithURL("http://cached.service.invalid:" + port + "/").WithDNSCache(cache).JSON(&status)
+		assert.Nil(err)
+		assert.Equal("ok!", status.Status)
+	}
+	assert.Equal(1, resolver.lookups)
+}
+
+func TestWithDNSCacheConnectTimeoutPerAddress(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer ts.Close()
+
+	_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	assert.Nil(err)
+
+	resolver := &fakeIPResolver{
+		addrs: map[string][]net.IPAddr{
+			"cached.service.invalid": {{IP: net.ParseIP("10.0.0.1")}, {IP: net.ParseIP("127.0.0.1")}},
+		},
+	}
+	dialer := &net.Dialer{}
+	var status statusObject
+	err = New().WithURL("http://cached.service.invalid:" + port + "/").
+		WithDNSCache(NewDNSCache(time.Minute).WithResolver(resolver)).
+		WithConnectTimeout(50 * time.Millisecond).
+		WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if strings.HasPrefix(addr, "10.0.0.1:") {
+				// an unreachable address that never answers.
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return dialer.DialContext(ctx, network, addr)
+		}).
+		JSON(&status)
+	assert.Nil(err)
+	assert.Equal("ok!", status.Status)
+}
@@ -0,0 +1,766 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+// ParseSchema parses a JSON Schema document.
+//
+// It supports a subset of draft 2020-12: `type`, `enum`, `const`, `properties`, `patternProperties`,
+// `additionalProperties`, `required`, `minProperties`, `maxProperties`, `items`, `prefixItems`, `minItems`,
+// `maxItems`, `uniqueItems`, `minLength`, `maxLength`, `pattern`, `minimum`, `maximum`, `exclusiveMinimum`,
+// `exclusiveMaximum`, `multipleOf`, `allOf`, `anyOf`, `oneOf`, `not`, and `$ref` to json pointers within the document
+// (e.g. `#/$defs/item`). Other keywords, including `format`, are ignored.
+func ParseSchema(contents []byte) (*Schema, error) {
+	document, err := decodeJSONValue(contents)
+	if err != nil {
+		return nil, wrap(err)
+	}
+
+	compiler := &schemaCompiler{document: document, schemas: map[string]*Schema{}}
+	root, err := compiler.compile(document, "#")
+	if err != nil {
+		return nil, err
+	}
+	for len(compiler.pending) > 0 {
+		schema := compiler.pending[0]
+		compiler.pending = compiler.pending[1:]
+		if schema.ref, err = compiler.resolve(schema.refPointer); err != nil {
+			return nil, err
+		}
+	}
+	if err := compiler.checkRefCycles(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// MustParseSchema parses a JSON Schema document and panics if it is invalid.
+func MustParseSchema(contents []byte) *Schema {
+	schema, err := ParseSchema(contents)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	boolean *bool
+
+	types    []string
+	enum     []interface{}
+	constant interface{}
+	hasConst bool
+
+	properties           map[string]*Schema
+	patternProperties    map[*regexp.Regexp]*Schema
+	additionalProperties *Schema
+	required             []string
+	minProperties        *int
+	maxProperties        *int
+
+	items       *Schema
+	prefixItems []*Schema
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	allOf []*Schema
+	anyOf []*Schema
+	oneOf []*Schema
+	not   *Schema
+
+	refPointer string
+	ref        *Schema
+}
+
+// Validate validates a json document against the schema, returning a `*SchemaValidationError` if it does not match.
+func (s *Schema) Validate(contents []byte) error {
+	value, err := decodeJSONValue(contents)
+	if err != nil {
+		return wrap(err)
+	}
+	var violations []SchemaViolation
+	s.validate(value, "", &violations)
+	if len(violations) > 0 {
+		return &SchemaValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// SchemaViolation is a single failed schema keyword.
+type SchemaViolation struct {
+	// Pointer is the json pointer to the failing value in the document.
+	Pointer string
+	Keyword string
+	Message string
+}
+
+// String returns the violation as `pointer: message`.
+func (sv SchemaViolation) String() string {
+	pointer := sv.Pointer
+	if isEmpty(pointer) {
+		pointer = "/"
+	}
+	return pointer + ": " + sv.Message
+}
+
+// SchemaValidationError is returned when a response does not match its schema.
+type SchemaValidationError struct {
+	Violations []SchemaViolation
+}
+
+// Error implements error.
+func (sve *SchemaValidationError) Error() string {
+	messages := make([]string, 0, len(sve.Violations))
+	for _, violation := range sve.Violations {
+		messages = append(messages, violation.String())
+	}
+	return "request: response failed schema validation: " + strings.Join(messages, "; ")
+}
+
+func (sve *SchemaValidationError) isTyped() {}
+
+type schemaCompiler struct {
+	document interface{}
+	schemas  map[string]*Schema
+	pending  []*Schema
+}
+
+func (sc *schemaCompiler) resolve(pointer string) (*Schema, error) {
+	if schema, hasSchema := sc.schemas[pointer]; hasSchema {
+		return schema, nil
+	}
+	if !strings.HasPrefix(pointer, "#") {
+		return nil, exception.Newf("schema: unsupported $ref %q; only json pointers within the document are supported", pointer)
+	}
+	node, err := lookupJSONPointer(sc.document, strings.TrimPrefix(pointer, "#"))
+	if err != nil {
+		return nil, err
+	}
+	return sc.compile(node, pointer)
+}
+
+// checkRefCycles returns an error if a `$ref` chain leads back to a schema it started from without
+// descending into the document, i.e. `{"$ref": "#"}`, which would never finish validating.
+// The subschemas of `allOf`, `anyOf`, `oneOf` and `not` apply to the same value as `$ref`, so they are followed too.
+func (sc *schemaCompiler) checkRefCycles() error {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	states := map[*Schema]int{}
+	var visit func(schema *Schema) *Schema
+	visit = func(schema *Schema) *Schema {
+		switch states[schema] {
+		case visiting:
+			return schema
+		case visited:
+			return nil
+		}
+		states[schema] = visiting
+		for _, next := range schema.inPlaceSchemas() {
+			if cycle := visit(next); cycle != nil {
+				return cycle
+			}
+		}
+		states[schema] = visited
+		return nil
+	}
+
+	pointers := make([]string, 0, len(sc.schemas))
+	for pointer := range sc.schemas {
+		pointers = append(pointers, pointer)
+	}
+	sort.Strings(pointers)
+	for _, pointer := range pointers {
+		cycle := visit(sc.schemas[pointer])
+		if cycle == nil {
+			continue
+		}
+		for _, cyclePointer := range pointers {
+			if sc.schemas[cyclePointer] == cycle {
+				return exception.Newf("schema: $ref cycle at %s never reaches a schema", cyclePointer)
+			}
+		}
+	}
+	return nil
+}
+
+// inPlaceSchemas returns the subschemas that validate the same value as the schema.
+func (s *Schema) inPlaceSchemas() []*Schema {
+	var schemas []*Schema
+	if s.ref != nil {
+		schemas = append(schemas, s.ref)
+	}
+	schemas = append(schemas, s.allOf...)
+	schemas = append(schemas, s.anyOf...)
+	schemas = append(schemas, s.oneOf...)
+	if s.not != nil {
+		schemas = append(schemas, s.not)
+	}
+	return schemas
+}
+
+func (sc *schemaCompiler) compile(node interface{}, pointer string) (*Schema, error) {
+	schema := &Schema{}
+	sc.schemas[pointer] = schema
+
+	if boolean, isBoolean := node.(bool); isBoolean {
+		schema.boolean = &boolean
+		return schema, nil
+	}
+	object, isObject := node.(map[string]interface{})
+	if !isObject {
+		return nil, exception.Newf("schema: %s must be an object or a boolean", pointer)
+	}
+
+	var err error
+	sub := func(keyword string, child interface{}) *Schema {
+		if err != nil {
+			return nil
+		}
+		var compiled *Schema
+		compiled, err = sc.compile(child, pointer+"/"+keyword)
+		return compiled
+	}
+	subs := func(keyword string, children interface{}) []*Schema {
+		list, isList := children.([]interface{})
+		if !isList {
+			err = exception.Newf("schema: %s/%s must be an array", pointer, keyword)
+			return nil
+		}
+		var compiled []*Schema
+		for index, child := range list {
+			compiled = append(compiled, sub(keyword+"/"+strconv.Itoa(index), child))
+		}
+		return compiled
+	}
+	nonNegative := func(keyword string, value interface{}) *int {
+		number, isNumber := jsonFloat(value)
+		if !isNumber || number < 0 || number != math.Trunc(number) {
+			err = exception.Newf("schema: %s/%s must be a non-negative integer", pointer, keyword)
+			return nil
+		}
+		converted := int(number)
+		return &converted
+	}
+	number := func(keyword string, value interface{}) *float64 {
+		converted, isNumber := jsonFloat(value)
+		if !isNumber {
+			err = exception.Newf("schema: %s/%s must be a number", pointer, keyword)
+			return nil
+		}
+		return &converted
+	}
+
+	for _, keyword := range sortedSchemaKeywords(object) {
+		value := object[keyword]
+		switch keyword {
+		case "type":
+			switch typed := value.(type) {
+			case string:
+				schema.types = []string{typed}
+			case []interface{}:
+				for _, item := range typed {
+					if name, isString := item.(string); isString {
+						schema.types = append(schema.types, name)
+					}
+				}
+			}
+		case "enum":
+			list, isList := value.([]interface{})
+			if !isList {
+				err = exception.Newf("schema: %s/enum must be an array", pointer)
+			}
+			schema.enum = list
+		case "const":
+			schema.constant = value
+			schema.hasConst = true
+		case "properties":
+			properties, isProperties := value.(map[string]interface{})
+			if !isProperties {
+				err = exception.Newf("schema: %s/properties must be an object", pointer)
+				break
+			}
+			schema.properties = map[string]*Schema{}
+			for name, child := range properties {
+				schema.properties[name] = sub("properties/"+escapeJSONPointer(name), child)
+			}
+		case "patternProperties":
+			properties, isProperties := value.(map[string]interface{})
+			if !isProperties {
+				err = exception.Newf("schema: %s/patternProperties must be an object", pointer)
+				break
+			}
+			schema.patternProperties = map[*regexp.Regexp]*Schema{}
+			for expr, child := range properties {
+				compiled, compileErr := regexp.Compile(expr)
+				if compileErr != nil {
+					err = exception.Newf("schema: %s/patternProperties has an invalid pattern %q", pointer, expr)
+					break
+				}
+				schema.patternProperties[compiled] = sub("patternProperties/"+escapeJSONPointer(expr), child)
+			}
+		case "additionalProperties":
+			schema.additionalProperties = sub(keyword, value)
+		case "required":
+			list, isList := value.([]interface{})
+			if !isList {
+				err = exception.Newf("schema: %s/required must be an array", pointer)
+				break
+			}
+			for _, item := range list {
+				if name, isString := item.(string); isString {
+					schema.required = append(schema.required, name)
+				}
+			}
+		case "minProperties":
+			schema.minProperties = nonNegative(keyword, value)
+		case "maxProperties":
+			schema.maxProperties = nonNegative(keyword, value)
+		case "items":
+			schema.items = sub(keyword, value)
+		case "prefixItems":
+			schema.prefixItems = subs(keyword, value)
+		case "minItems":
+			schema.minItems = nonNegative(keyword, value)
+		case "maxItems":
+			schema.maxItems = nonNegative(keyword, value)
+		case "uniqueItems":
+			schema.uniqueItems, _ = value.(bool)
+		case "minLength":
+			schema.minLength = nonNegative(keyword, value)
+		case "maxLength":
+			schema.maxLength = nonNegative(keyword, value)
+		case "pattern":
+			expr, _ := value.(string)
+			compiled, compileErr := regexp.Compile(expr)
+			if compileErr != nil {
+				err = exception.Newf("schema: %s/pattern is invalid: %v", pointer, compileErr)
+				break
+			}
+			schema.pattern = compiled
+		case "minimum":
+			schema.minimum = number(keyword, value)
+		case "maximum":
+			schema.maximum = number(keyword, value)
+		case "exclusiveMinimum":
+			schema.exclusiveMinimum = number(keyword, value)
+		case "exclusiveMaximum":
+			schema.exclusiveMaximum = number(keyword, value)
+		case "multipleOf":
+			schema.multipleOf = number(keyword, value)
+			if schema.multipleOf != nil && *schema.multipleOf <= 0 {
+				err = exception.Newf("schema: %s/multipleOf must be greater than zero", pointer)
+			}
+		case "allOf":
+			schema.allOf = subs(keyword, value)
+		case "anyOf":
+			schema.anyOf = subs(keyword, value)
+		case "oneOf":
+			schema.oneOf = subs(keyword, value)
+		case "not":
+			schema.not = sub(keyword, value)
+		case "$ref":
+			ref, _ := value.(string)
+			schema.refPointer = ref
+			sc.pending = append(sc.pending, schema)
+		case "$defs", "definitions":
+			definitions, isDefinitions := value.(map[string]interface{})
+			if !isDefinitions {
+				err = exception.Newf("schema: %s/%s must be an object", pointer, keyword)
+				break
+			}
+			for name, child := range definitions {
+				sub(keyword+"/"+escapeJSONPointer(name), child)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return schema, nil
+}
+
+func (s *Schema) validate(value interface{}, pointer string, violations *[]SchemaViolation) {
+	violation := func(keyword, format string, args ...interface{}) {
+		*violations = append(*violations, SchemaViolation{Pointer: pointer, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.boolean != nil {
+		if !*s.boolean {
+			violation("false", "no value is allowed")
+		}
+		return
+	}
+	if s.ref != nil {
+		s.ref.validate(value, pointer, violations)
+	}
+
+	if len(s.types) > 0 && !matchesJSONType(value, s.types) {
+		violation("type", "expected %s, got %s", strings.Join(s.types, " or "), jsonType(value))
+		return
+	}
+	if s.enum != nil {
+		var found bool
+		for _, option := range s.enum {
+			if jsonEqual(value, option) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			violation("enum", "value is not one of the allowed values")
+		}
+	}
+	if s.hasConst && !jsonEqual(value, s.constant) {
+		violation("const", "value does not match the constant")
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		s.validateObject(typed, pointer, violation, violations)
+	case []interface{}:
+		s.validateArray(typed, pointer, violation, violations)
+	case string:
+		length := utf8.RuneCountInString(typed)
+		if s.minLength != nil && length < *s.minLength {
+			violation("minLength", "length %d is less than %d", length, *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			violation("maxLength", "length %d is greater than %d", length, *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(typed) {
+			violation("pattern", "does not match pattern %q", s.pattern.String())
+		}
+	case json.Number:
+		number, _ := jsonFloat(typed)
+		if s.minimum != nil && number < *s.minimum {
+			violation("minimum", "%v is less than %v", number, *s.minimum)
+		}
+		if s.maximum != nil && number > *s.maximum {
+			violation("maximum", "%v is greater than %v", number, *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && number <= *s.exclusiveMinimum {
+			violation("exclusiveMinimum", "%v is not greater than %v", number, *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && number >= *s.exclusiveMaximum {
+			violation("exclusiveMaximum", "%v is not less than %v", number, *s.exclusiveMaximum)
+		}
+		if s.multipleOf != nil {
+			quotient := number / *s.multipleOf
+			if math.Abs(quotient-math.Floor(quotient+0.5)) > 1e-9 {
+				violation("multipleOf", "%v is not a multiple of %v", number, *s.multipleOf)
+			}
+		}
+	}
+
+	for _, schema := range s.allOf {
+		schema.validate(value, pointer, violations)
+	}
+	if len(s.anyOf) > 0 {
+		var matched bool
+		for _, schema := range s.anyOf {
+			if schema.matches(value, pointer) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			violation("anyOf", "does not match any of the allowed schemas")
+		}
+	}
+	if len(s.oneOf) > 0 {
+		var matched int
+		for _, schema := range s.oneOf {
+			if schema.matches(value, pointer) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			violation("oneOf", "matches %d of the schemas instead of exactly one", matched)
+		}
+	}
+	if s.not != nil && s.not.matches(value, pointer) {
+		violation("not", "matches a schema it must not match")
+	}
+}
+
+func (s *Schema) validateObject(object map[string]interface{}, pointer string, violation func(keyword, format string, args ...interface{}), violations *[]SchemaViolation) {
+	for _, name := range s.required {
+		if _, hasProperty := object[name]; !hasProperty {
+			*violations = append(*violations, SchemaViolation{
+				Pointer: pointer + "/" + escapeJSONPointer(name),
+				Keyword: "required",
+				Message: "required property is missing",
+			})
+		}
+	}
+	if s.minProperties != nil && len(object) < *s.minProperties {
+		violation("minProperties", "has %d properties, fewer than %d", len(object), *s.minProperties)
+	}
+	if s.maxProperties != nil && len(object) > *s.maxProperties {
+		violation("maxProperties", "has %d properties, more than %d", len(object), *s.maxProperties)
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		propertyPointer := pointer + "/" + escapeJSONPointer(name)
+		var evaluated bool
+		if property, hasProperty := s.properties[name]; hasProperty {
+			property.validate(object[name], propertyPointer, violations)
+			evaluated = true
+		}
+		for expr, property := range s.patternProperties {
+			if expr.MatchString(name) {
+				property.validate(object[name], propertyPointer, violations)
+				evaluated = true
+			}
+		}
+		if !evaluated && s.additionalProperties != nil {
+			if s.additionalProperties.boolean != nil && !*s.additionalProperties.boolean {
+				*violations = append(*violations, SchemaViolation{Pointer: propertyPointer, Keyword: "additionalProperties", Message: "additional property is not allowed"})
+				continue
+			}
+			s.additionalProperties.validate(object[name], propertyPointer, violations)
+		}
+	}
+}
+
+func (s *Schema) validateArray(array []interface{}, pointer string, violation func(keyword, format string, args ...interface{}), violations *[]SchemaViolation) {
+	if s.minItems != nil && len(array) < *s.minItems {
+		violation("minItems", "has %d items, fewer than %d", len(array), *s.minItems)
+	}
+	if s.maxItems != nil && len(array) > *s.maxItems {
+		violation("maxItems", "has %d items, more than %d", len(array), *s.maxItems)
+	}
+	for index, item := range array {
+		itemPointer := pointer + "/" + strconv.Itoa(index)
+		if index < len(s.prefixItems) {
+			s.prefixItems[index].validate(item, itemPointer, violations)
+		} else if s.items != nil {
+			s.items.validate(item, itemPointer, violations)
+		}
+	}
+	if s.uniqueItems {
+		for index := range array {
+			for other := index + 1; other < len(array); other++ {
+				if jsonEqual(array[index], array[other]) {
+					violation("uniqueItems", "items %d and %d are equal", index, other)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Schema) matches(value interface{}, pointer string) bool {
+	var violations []SchemaViolation
+	s.validate(value, pointer, &violations)
+	return len(violations) == 0
+}
+
+// sortedSchemaKeywords returns the keywords of a schema object in a stable order so compile errors are deterministic.
+func sortedSchemaKeywords(object map[string]interface{}) []string {
+	keywords := make([]string, 0, len(object))
+	for keyword := range object {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	return keywords
+}
+
+func decodeJSONValue(contents []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func jsonType(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if number, _ := jsonFloat(typed); number == math.Trunc(number) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func matchesJSONType(value interface{}, types []string) bool {
+	actual := jsonType(value)
+	for _, expected := range types {
+		if expected == actual || (expected == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonFloat(value interface{}) (float64, bool) {
+	switch typed := value.(type) {
+	case json.Number:
+		number, err := typed.Float64()
+		return number, err == nil
+	case float64:
+		return typed, true
+	}
+	return 0, false
+}
+
+func jsonEqual(a, b interface{}) bool {
+	switch typedA := a.(type) {
+	case json.Number:
+		numberA, _ := jsonFloat(typedA)
+		numberB, isNumber := jsonFloat(b)
+		return isNumber && numberA == numberB
+	case []interface{}:
+		typedB, isArray := b.([]interface{})
+		if !isArray || len(typedA) != len(typedB) {
+			return false
+		}
+		for index := range typedA {
+			if !jsonEqual(typedA[index], typedB[index]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		typedB, isObject := b.(map[string]interface{})
+		if !isObject || len(typedA) != len(typedB) {
+			return false
+		}
+		for key, value := range typedA {
+			other, hasKey := typedB[key]
+			if !hasKey || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// lookupJSONPointer returns the value at an RFC 6901 json pointer in a document.
+func lookupJSONPointer(document interface{}, pointer string) (interface{}, error) {
+	if isEmpty(pointer) {
+		return document, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, exception.Newf("schema: invalid json pointer %q", pointer)
+	}
+	value := document
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			child, hasChild := typed[token]
+			if !hasChild {
+				return nil, exception.Newf("schema: $ref %q not found", pointer)
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(typed) {
+				return nil, exception.Newf("schema: $ref %q not found", pointer)
+			}
+			value = typed[index]
+		default:
+			return nil, exception.Newf("schema: $ref %q not found", pointer)
+		}
+	}
+	return value, nil
+}
+
+func escapeJSONPointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+// WithResponseSchema validates successful (2xx) response bodies against a json schema before they are deserialized.
+// Responses that do not match fail with a `*SchemaValidationError`.
+func (hr *Request) WithResponseSchema(schema *Schema) *Request {
+	hr.responseSchema = schema
+	hr.responseSchemaLogOnly = false
+	return hr
+}
+
+// WithResponseSchemaLogOnly validates the response body against a json schema, but only reports violations
+// as `EventSchemaViolation` events to the logger instead of failing the request.
+func (hr *Request) WithResponseSchemaLogOnly(schema *Schema) *Request {
+	hr.responseSchema = schema
+	hr.responseSchemaLogOnly = true
+	return hr
+}
+
+// validateResponse validates successful (2xx) response bodies against the response schema;
+// error responses are not expected to match it.
+func (hr *Request) validateResponse(meta *ResponseMeta, body []byte) error {
+	if hr.responseSchema == nil || meta == nil || meta.StatusCode < http.StatusOK || meta.StatusCode >= http.StatusMultipleChoices {
+		return nil
+	}
+	err := hr.responseSchema.Validate(body)
+	if err == nil {
+		return nil
+	}
+
+	violations, isViolation := err.(*SchemaValidationError)
+	if !isViolation {
+		if !hr.responseSchemaLogOnly {
+			hr.logError(err, ErrorClassDecode)
+			return err
+		}
+		// the body is not json; report it as a violation of the whole document.
+		violations = &SchemaValidationError{
+			Violations: []SchemaViolation{{Keyword: "json", Message: fmt.Sprintf("invalid json: %v", err)}},
+		}
+	}
+	if hr.logger != nil {
+		hr.logger.OnEvent(EventSchemaViolation, hr.Meta(), violations)
+	}
+	if hr.responseSchemaLogOnly {
+		return nil
+	}
+	hr.logError(violations, ErrorClassSchema)
+	return violations
+}
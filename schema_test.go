@@ -0,0 +1,205 @@
+package request
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+const testSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["id", "status"],
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"status": {"enum": ["ok!", "error"]},
+		"name": {"type": "string", "minLength": 1, "maxLength": 8, "pattern": "^[a-z]+$"},
+		"tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true, "maxItems": 3},
+		"owner": {"$ref": "#/$defs/owner"}
+	},
+	"additionalProperties": false,
+	"$defs": {
+		"owner": {
+			"type": "object",
+			"required": ["email"],
+			"properties": {
+				"email": {"type": "string"},
+				"manager": {"anyOf": [{"type": "null"}, {"$ref": "#/$defs/owner"}]}
+			}
+		}
+	}
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	schema, err := ParseSchema([]byte(testSchema))
+	assert.Nil(err)
+
+	assert.Nil(schema.Validate([]byte(`{"id":1,"status":"ok!","name":"foo","tags":["a","b"],"owner":{"email":"a@b.c","manager":{"email":"d@e.f","manager":null}}}`)))
+
+	err = schema.Validate([]byte(`{"id":1.5,"name":"Foo Bar Baz","tags":["a","a"],"owner":{"manager":{}},"extra":true}`))
+	assert.NotNil(err)
+	var validationErr *SchemaValidationError
+	assert.True(errors.As(err, &validationErr))
+
+	pointers := map[string]string{}
+	for _, violation := range validationErr.Violations {
+		pointers[violation.Pointer+" "+violation.Keyword] = violation.Message
+	}
+	assert.NotEmpty(pointers["/status required"])
+	assert.NotEmpty(pointers["/id type"])
+	assert.NotEmpty(pointers["/name maxLength"])
+	assert.NotEmpty(pointers["/name pattern"])
+	assert.NotEmpty(pointers["/tags uniqueItems"])
+	assert.NotEmpty(pointers["/owner/email required"])
+	assert.NotEmpty(pointers["/owner/manager anyOf"])
+	assert.NotEmpty(pointers["/extra additionalProperties"])
+	assert.Equal(ErrorClassSchema, ClassifyError(err))
+}
+
+func TestSchemaKeywords(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		Schema string
+		Valid  string
+		Error  string
+	}{
+		{`{"const": {"a": [1, 2]}}`, `{"a": [1.0, 2]}`, `{"a": [2, 1]}`},
+		{`{"type": ["string", "null"]}`, `null`, `1`},
+		{`{"type": "number", "exclusiveMinimum": 0, "exclusiveMaximum": 10}`, `9.5`, `10`},
+		{`{"multipleOf": 0.1}`, `0.3`, `0.35`},
+		{`{"oneOf": [{"type": "integer"}, {"minimum": 2}]}`, `1`, `3`},
+		{`{"not": {"type": "string"}}`, `1`, `"a"`},
+		{`{"allOf": [{"minimum": 1}, {"maximum": 2}]}`, `2`, `3`},
+		{`{"prefixItems": [{"type": "string"}], "items": {"type": "integer"}, "minItems": 2}`, `["a", 1, 2]`, `["a", "b"]`},
+		{`{"patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": {"type": "integer"}}`, `{"x-a": "a", "b": 1}`, `{"x-a": 1}`},
+		{`{"minProperties": 1, "maxProperties": 1}`, `{"a": 1}`, `{}`},
+		{`false`, ``, `{}`},
+		{`true`, `{}`, ``},
+	}
+
+	for _, testCase := range testCases {
+		schema, err := ParseSchema([]byte(testCase.Schema))
+		assert.Nil(err, testCase.Schema)
+		if len(testCase.Valid) > 0 {
+			assert.Nil(schema.Validate([]byte(testCase.Valid)), testCase.Schema)
+		}
+		if len(testCase.Error) > 0 {
+			assert.NotNil(schema.Validate([]byte(testCase.Error)), testCase.Schema)
+		}
+	}
+}
+
+func TestParseSchemaInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, schema := range []string{
+		`{"properties": {"a": 1}}`,
+		`{"$ref": "#/$defs/missing"}`,
+		`{"$ref": "https://example.com/schema.json"}`,
+		`{"pattern": "("}`,
+		`{"minLength": -1}`,
+		`{"multipleOf": 0}`,
+		`not json`,
+	} {
+		_, err := ParseSchema([]byte(schema))
+		assert.NotNil(err, schema)
+	}
+}
+
+func TestParseSchemaRefCycle(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, schema := range []string{
+		`{"$ref": "#"}`,
+		`{"$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`,
+		`{"$defs": {"a": {"allOf": [{"$ref": "#/$defs/a"}]}}, "$ref": "#/$defs/a"}`,
+	} {
+		_, err := ParseSchema([]byte(schema))
+		assert.NotNil(err, schema)
+	}
+
+	// recursion that descends into the document is fine.
+	schema, err := ParseSchema([]byte(`{"type": "object", "properties": {"child": {"$ref": "#"}}}`))
+	assert.Nil(err)
+	assert.Nil(schema.Validate([]byte(`{"child": {"child": {}}}`)))
+	assert.NotNil(schema.Validate([]byte(`{"child": {"child": 1}}`)))
+}
+
+func TestWithResponseSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer ts.Close()
+
+	var status statusObject
+	err := New().WithURL(ts.URL).WithResponseSchema(MustParseSchema([]byte(`{"type":"object","required":["status"]}`))).JSON(&status)
+	assert.Nil(err)
+	assert.Equal("ok!", status.Status)
+
+	status = statusObject{}
+	var class ErrorClass
+	err = New().WithURL(ts.URL).
+		WithResponseSchema(MustParseSchema([]byte(`{"type":"object","required":["id"]}`))).
+		OnError(func(req *Meta, err error) { class = ClassifyError(err) }).
+		JSON(&status)
+	assert.NotNil(err)
+	assert.Empty(status.Status)
+	assert.Equal(ErrorClassSchema, class)
+}
+
+func TestWithResponseSchemaLogOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(okMeta(), statusOkObject(), nil)
+	defer ts.Close()
+
+	var status statusObject
+	var errored bool
+	err := New().WithURL(ts.URL).
+		WithResponseSchemaLogOnly(MustParseSchema([]byte(`{"type":"object","required":["id"]}`))).
+		OnError(func(req *Meta, err error) { errored = true }).
+		JSON(&status)
+	assert.Nil(err)
+	assert.False(errored)
+	assert.Equal("ok!", status.Status)
+}
+
+func TestWithResponseSchemaErrorResponse(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := mockEndpoint(notFoundMeta(), statusObject{Status: "not found"}, nil)
+	defer ts.Close()
+
+	var status, errorStatus statusObject
+	meta, err := New().WithURL(ts.URL).
+		WithResponseSchema(MustParseSchema([]byte(`{"required":["id"]}`))).
+		JSONWithErrorHandler(&status, &errorStatus)
+	assert.Nil(err)
+	assert.Equal(http.StatusNotFound, meta.StatusCode)
+	assert.Equal("not found", errorStatus.Status)
+}
+
+func TestWithResponseSchemaSuccessStatusesOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	schema := MustParseSchema([]byte(`{"required":["id"]}`))
+
+	notFound := mockEndpoint(notFoundMeta(), statusObject{Status: "not found"}, nil)
+	defer notFound.Close()
+
+	var status statusObject
+	err := New().WithURL(notFound.URL).WithResponseSchema(schema).JSON(&status)
+	assert.Nil(err)
+	assert.Equal("not found", status.Status)
+
+	created := mockEndpoint(&ResponseMeta{StatusCode: http.StatusCreated}, statusOkObject(), nil)
+	defer created.Close()
+
+	_, err = New().WithURL(created.URL).WithResponseSchema(schema).JSONWithErrorHandler(&status, &statusObject{})
+	assert.NotNil(err)
+}
@@ -0,0 +1,266 @@
+package request
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+const (
+	// TagQuery is the struct tag read by `WithQueryFromObject`.
+	TagQuery = "url"
+	// TagHeader is the struct tag read by `WithHeadersFromObject`.
+	TagHeader = "header"
+	// TagLayout is the struct tag that sets the time layout of a `time.Time` field.
+	TagLayout = "layout"
+)
+
+// WithQueryFromObject adds query string values from the fields of a struct tagged with `url:"name"`.
+//
+// Tag options (after the name) are:
+//   - `omitempty` skips zero values
+//   - `comma` joins slices into a single comma separated value instead of repeating the field
+//   - `unix` formats times as unix seconds
+//
+// Times use the layout from a `layout:"..."` tag, or RFC3339 by default. Pointers are dereferenced and nil pointers are skipped.
+// Embedded structs without a tag contribute their own fields. Fields without the tag are ignored.
+func (hr *Request) WithQueryFromObject(object interface{}) *Request {
+	fields, err := taggedFields(object, TagQuery)
+	if err != nil {
+		hr.err = err
+		return hr
+	}
+	if hr.QueryString == nil {
+		hr.QueryString = url.Values{}
+	}
+	for _, field := range fields {
+		for _, value := range field.values {
+			hr.QueryString.Add(field.name, value)
+		}
+	}
+	return hr
+}
+
+// WithHeadersFromObject sets headers from the fields of a struct tagged with `header:"X-Name"`.
+// It supports the same tag options and field types as `WithQueryFromObject`;
+// slices without the `comma` option are joined into a single `, ` separated value, the equivalent of repeating the header.
+func (hr *Request) WithHeadersFromObject(object interface{}) *Request {
+	fields, err := taggedFields(object, TagHeader)
+	if err != nil {
+		hr.err = err
+		return hr
+	}
+	if hr.Header == nil {
+		hr.Header = http.Header{}
+	}
+	for _, field := range fields {
+		if len(field.values) == 0 {
+			continue
+		}
+		hr.Header.Set(field.name, strings.Join(field.values, ", "))
+	}
+	return hr
+}
+
+type taggedField struct {
+	name   string
+	values []string
+}
+
+type tagOptions struct {
+	omitEmpty bool
+	comma     bool
+	unix      bool
+}
+
+func parseTag(tag string) (string, tagOptions) {
+	pieces := strings.Split(tag, ",")
+	var options tagOptions
+	for _, option := range pieces[1:] {
+		switch strings.TrimSpace(option) {
+		case "omitempty":
+			options.omitEmpty = true
+		case "comma":
+			options.comma = true
+		case "unix":
+			options.unix = true
+		}
+	}
+	return strings.TrimSpace(pieces[0]), options
+}
+
+// taggedFields returns the formatted values of the fields of a struct that have a given tag.
+func taggedFields(object interface{}, tagName string) ([]taggedField, error) {
+	value := indirect(reflect.ValueOf(object))
+	if !value.IsValid() {
+		return nil, nil
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, exception.Newf("cannot read %s tags from a %s", tagName, value.Kind())
+	}
+	return structTaggedFields(value, tagName)
+}
+
+func structTaggedFields(value reflect.Value, tagName string) ([]taggedField, error) {
+	var fields []taggedField
+	valueType := value.Type()
+	for index := 0; index < valueType.NumField(); index++ {
+		fieldType := valueType.Field(index)
+		tag, hasTag := fieldType.Tag.Lookup(tagName)
+		if tag == "-" {
+			continue
+		}
+
+		fieldValue := value.Field(index)
+		if fieldType.Anonymous && !hasTag {
+			if embedded := indirect(fieldValue); embedded.IsValid() && embedded.Kind() == reflect.Struct {
+				embeddedFields, err := structTaggedFields(embedded, tagName)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, embeddedFields...)
+			}
+			continue
+		}
+		if !hasTag || len(fieldType.PkgPath) > 0 {
+			continue
+		}
+
+		name, options := parseTag(tag)
+		if isEmpty(name) {
+			name = fieldType.Name
+		}
+		if options.omitEmpty && isZeroValue(fieldValue) {
+			continue
+		}
+		fieldValue = indirect(fieldValue)
+		if !fieldValue.IsValid() {
+			continue
+		}
+
+		values, err := formatTaggedValues(fieldValue, fieldType, options)
+		if err != nil {
+			return nil, err
+		}
+		if options.comma && len(values) > 0 {
+			values = []string{strings.Join(values, ",")}
+		}
+		fields = append(fields, taggedField{name: name, values: values})
+	}
+	return fields, nil
+}
+
+// indirect dereferences pointers and interfaces, returning the zero value if any of them are nil.
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+func formatTaggedValues(value reflect.Value, field reflect.StructField, options tagOptions) ([]string, error) {
+	if (value.Kind() == reflect.Slice || value.Kind() == reflect.Array) && value.Type().Elem().Kind() != reflect.Uint8 {
+		values := make([]string, 0, value.Len())
+		for index := 0; index < value.Len(); index++ {
+			formatted, err := formatTaggedValue(value.Index(index), field, options)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, formatted)
+		}
+		return values, nil
+	}
+	formatted, err := formatTaggedValue(value, field, options)
+	if err != nil {
+		return nil, err
+	}
+	return []string{formatted}, nil
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+func formatTaggedValue(value reflect.Value, field reflect.StructField, options tagOptions) (string, error) {
+	value = indirect(value)
+	if !value.IsValid() {
+		return "", nil
+	}
+
+	switch value.Type() {
+	case timeType:
+		timestamp := value.Interface().(time.Time)
+		if options.unix {
+			return strconv.FormatInt(timestamp.Unix(), 10), nil
+		}
+		if layout := field.Tag.Get(TagLayout); !isEmpty(layout) {
+			return timestamp.Format(layout), nil
+		}
+		return timestamp.Format(time.RFC3339), nil
+	case durationType:
+		return value.Interface().(time.Duration).String(), nil
+	}
+
+	if marshaler, isMarshaler := value.Interface().(encoding.TextMarshaler); isMarshaler {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64), nil
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return string(value.Bytes()), nil
+		}
+	}
+
+	if stringer, isStringer := value.Interface().(fmt.Stringer); isStringer {
+		return stringer.String(), nil
+	}
+	return "", exception.Newf("cannot format field %s of type %s", field.Name, value.Type())
+}
+
+func isZeroValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool:
+		return !value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() == 0
+	case reflect.Struct:
+		if zeroer, isZeroer := value.Interface().(interface{ IsZero() bool }); isZeroer {
+			return zeroer.IsZero()
+		}
+		return reflect.DeepEqual(value.Interface(), reflect.Zero(value.Type()).Interface())
+	}
+	return false
+}
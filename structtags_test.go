@@ -0,0 +1,129 @@
+package request
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+type pageParams struct {
+	Limit  int    `url:"limit,omitempty"`
+	Cursor string `url:"cursor,omitempty"`
+}
+
+type searchParams struct {
+	pageParams
+
+	Query    string        `url:"q"`
+	IDs      []int         `url:"id"`
+	Tags     []string      `url:"tags,comma"`
+	Since    time.Time     `url:"since" layout:"2006-01-02"`
+	Until    time.Time     `url:"until,unix"`
+	Archived *bool         `url:"archived,omitempty"`
+	Verbose  *bool         `url:"verbose"`
+	MaxAge   time.Duration `url:"max_age"`
+	Skipped  string        `url:"-"`
+	Untagged string
+}
+
+type searchHeaders struct {
+	RequestID string   `header:"X-Request-Id"`
+	Accept    []string `header:"Accept"`
+	Features  []string `header:"X-Features,comma"`
+	Empty     string   `header:"X-Empty,omitempty"`
+	Retries   *int     `header:"X-Retries"`
+}
+
+func TestWithQueryFromObject(t *testing.T) {
+	assert := assert.New(t)
+
+	archived := false
+	req := New().WithHost("api.test.com").WithQueryString("existing", "1").WithQueryFromObject(&searchParams{
+		pageParams: pageParams{Limit: 10},
+		Query:      "a b",
+		IDs:        []int{1, 2},
+		Tags:       []string{"x", "y"},
+		Since:      time.Date(2017, 02, 03, 4, 5, 6, 0, time.UTC),
+		Until:      time.Unix(1500000000, 0),
+		Archived:   &archived,
+		MaxAge:     90 * time.Second,
+		Skipped:    "skipped",
+		Untagged:   "untagged",
+	})
+
+	query := req.QueryString
+	assert.Equal("1", query.Get("existing"))
+	assert.Equal("10", query.Get("limit"))
+	assert.Empty(query["cursor"])
+	assert.Equal("a b", query.Get("q"))
+	assert.Equal([]string{"1", "2"}, query["id"])
+	assert.Equal("x,y", query.Get("tags"))
+	assert.Equal("2017-02-03", query.Get("since"))
+	assert.Equal("1500000000", query.Get("until"))
+	assert.Equal("false", query.Get("archived"))
+	assert.Empty(query["verbose"])
+	assert.Equal("1m30s", query.Get("max_age"))
+	assert.Empty(query["Skipped"])
+	assert.Empty(query["Untagged"])
+}
+
+func TestWithQueryFromObjectInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := New().WithHost("api.test.com").WithQueryFromObject("not a struct").Request()
+	assert.NotNil(err)
+
+	_, err = New().WithHost("api.test.com").WithQueryFromObject(struct {
+		Nested map[string]string `url:"nested"`
+	}{Nested: map[string]string{"a": "b"}}).Request()
+	assert.NotNil(err)
+}
+
+func TestWithHeadersFromObject(t *testing.T) {
+	assert := assert.New(t)
+
+	var headers http.Header
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		writeJSON(w, okMeta(), statusOkObject())
+	})
+	defer ts.Close()
+
+	retries := 3
+	err := New().WithURL(ts.URL).WithHeadersFromObject(searchHeaders{
+		RequestID: "abc",
+		Accept:    []string{"application/json", "text/plain"},
+		Features:  []string{"a", "b"},
+		Retries:   &retries,
+	}).Execute()
+	assert.Nil(err)
+	assert.Equal("abc", headers.Get("X-Request-Id"))
+	assert.Equal([]string{"application/json, text/plain"}, headers["Accept"])
+	assert.Equal("a,b", headers.Get("X-Features"))
+	assert.Equal("3", headers.Get("X-Retries"))
+	_, hasEmpty := headers["X-Empty"]
+	assert.False(hasEmpty)
+}
+
+func TestHeadersOverrideBasicAuthAndCookies(t *testing.T) {
+	assert := assert.New(t)
+
+	var headers http.Header
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		writeJSON(w, okMeta(), statusOkObject())
+	})
+	defer ts.Close()
+
+	err := New().WithURL(ts.URL).
+		WithBasicAuth("user", "pass").
+		WithHeader("Authorization", "Bearer t").
+		WithCookie(&http.Cookie{Name: "session", Value: "a"}).
+		WithHeader("Cookie", "session=b").
+		Execute()
+	assert.Nil(err)
+	assert.Equal([]string{"Bearer t"}, headers["Authorization"])
+	assert.Equal([]string{"session=b"}, headers["Cookie"])
+}
@@ -0,0 +1,245 @@
+package request
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// TimeoutPhase is the phase of a request a timeout applies to.
+type TimeoutPhase string
+
+const (
+	// TimeoutPhaseConnect is the time allowed to open a connection, see `WithConnectTimeout`.
+	TimeoutPhaseConnect TimeoutPhase = "connect"
+	// TimeoutPhaseTLSHandshake is the time allowed for the tls handshake, see `WithTLSHandshakeTimeout`.
+	TimeoutPhaseTLSHandshake TimeoutPhase = "tls handshake"
+	// TimeoutPhaseResponseHeader is the time allowed between writing the request and reading the response headers, see `WithResponseHeaderTimeout`.
+	TimeoutPhaseResponseHeader TimeoutPhase = "response header"
+	// TimeoutPhaseBodyRead is the time allowed between reads of the response body, see `WithBodyReadTimeout`.
+	TimeoutPhaseBodyRead TimeoutPhase = "body read"
+	// TimeoutPhaseOverall is the time allowed for the whole request including reading the body, see `WithTimeout`.
+	TimeoutPhaseOverall TimeoutPhase = "overall"
+)
+
+// TimeoutError is returned when a request runs out of time in a given phase.
+type TimeoutError struct {
+	Phase    TimeoutPhase
+	Duration time.Duration
+	Err      error
+}
+
+// Error implements error.
+func (te *TimeoutError) Error() string {
+	return fmt.Sprintf("request: %s timeout of %v exceeded: %v", te.Phase, te.Duration, te.Err)
+}
+
+// Unwrap returns the underlying error.
+func (te *TimeoutError) Unwrap() error {
+	return te.Err
+}
+
+// Timeout implements net.Error.
+func (te *TimeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary implements net.Error.
+func (te *TimeoutError) Temporary() bool {
+	return true
+}
+
+func (te *TimeoutError) isTyped() {}
+
+// WithConnectTimeout sets the time allowed to open a connection.
+// If it is not set, the overall timeout is also used as the connect timeout.
+func (hr *Request) WithConnectTimeout(timeout time.Duration) *Request {
+	hr.ConnectTimeout = timeout
+	return hr
+}
+
+// WithTLSHandshakeTimeout sets the time allowed for the tls handshake.
+func (hr *Request) WithTLSHandshakeTimeout(timeout time.Duration) *Request {
+	hr.TLSHandshakeTimeout = timeout
+	return hr
+}
+
+// WithResponseHeaderTimeout sets the time allowed between writing the request and reading the response headers.
+func (hr *Request) WithResponseHeaderTimeout(timeout time.Duration) *Request {
+	hr.ResponseHeaderTimeout = timeout
+	return hr
+}
+
+// WithBodyReadTimeout sets the time the response body may go without delivering any data.
+// Remarks: unlike `WithTimeout` this does not limit the total download time.
+func (hr *Request) WithBodyReadTimeout(timeout time.Duration) *Request {
+	hr.BodyReadTimeout = timeout
+	return hr
+}
+
+func (hr *Request) requiresCustomTimeouts() bool {
+	return hr.ConnectTimeout != time.Duration(0) ||
+		hr.TLSHandshakeTimeout != time.Duration(0) ||
+		hr.ResponseHeaderTimeout != time.Duration(0)
+}
+
+func (hr *Request) connectTimeout() time.Duration {
+	if hr.ConnectTimeout != time.Duration(0) {
+		return hr.ConnectTimeout
+	}
+	return hr.Timeout
+}
+
+// dialWithTimeout bounds a dial function by the connect timeout, reporting expirations as `TimeoutPhaseConnect`.
+func dialWithTimeout(dial DialFunc, timeout time.Duration) DialFunc {
+	if timeout == time.Duration(0) {
+		return dial
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		conn, err := dial(dialCtx, network, addr)
+		if err != nil && ctx.Err() == nil && (dialCtx.Err() == context.DeadlineExceeded || isTimeout(err)) {
+			return nil, &TimeoutError{Phase: TimeoutPhaseConnect, Duration: timeout, Err: err}
+		}
+		return conn, err
+	}
+}
+
+// timeoutPhases follows the phase a request attempt is in with a client trace,
+// so timeouts can be attributed to the phase they expired in.
+type timeoutPhases struct {
+	sync.Mutex
+	phase TimeoutPhase
+}
+
+func (tp *timeoutPhases) set(phase TimeoutPhase) {
+	tp.Lock()
+	tp.phase = phase
+	tp.Unlock()
+}
+
+func (tp *timeoutPhases) current() TimeoutPhase {
+	tp.Lock()
+	defer tp.Unlock()
+	return tp.phase
+}
+
+// traceTimeoutPhases returns the request with a client trace that records its timeout phase.
+// Phases without their own timeout, like writing the request, are recorded as `TimeoutPhaseOverall`.
+func traceTimeoutPhases(req *http.Request) (*http.Request, *timeoutPhases) {
+	tp := &timeoutPhases{phase: TimeoutPhaseConnect}
+	trace := &httptrace.ClientTrace{
+		GetConn:           func(string) { tp.set(TimeoutPhaseConnect) },
+		TLSHandshakeStart: func() { tp.set(TimeoutPhaseTLSHandshake) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				tp.set(TimeoutPhaseOverall)
+			}
+		},
+		GotConn:              func(httptrace.GotConnInfo) { tp.set(TimeoutPhaseOverall) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { tp.set(TimeoutPhaseResponseHeader) },
+		GotFirstResponseByte: func() { tp.set(TimeoutPhaseOverall) },
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), tp
+}
+
+// timeoutError returns a `TimeoutError` for an http client error that happened in the given phase.
+// The phase is reported if its own timeout, or for connecting the overall timeout, is what expired;
+// otherwise it is reported as `TimeoutPhaseOverall`.
+func (hr *Request) timeoutError(err error, phase TimeoutPhase) error {
+	var typed *TimeoutError
+	if errors.As(err, &typed) {
+		return typed
+	}
+	// deadlines on the caller's context are not ours to report.
+	if !isTimeout(err) || hr.Context().Err() != nil {
+		return err
+	}
+
+	var limit time.Duration
+	switch phase {
+	case TimeoutPhaseConnect:
+		limit = hr.connectTimeout()
+	case TimeoutPhaseTLSHandshake:
+		limit = hr.TLSHandshakeTimeout
+	case TimeoutPhaseResponseHeader:
+		limit = hr.ResponseHeaderTimeout
+	}
+	if limit == time.Duration(0) || (hr.Timeout != time.Duration(0) && hr.Timeout < limit) {
+		phase, limit = TimeoutPhaseOverall, hr.Timeout
+	}
+	if limit == time.Duration(0) {
+		return err
+	}
+	return &TimeoutError{Phase: phase, Duration: limit, Err: err}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// newTimeoutBody wraps a response body so read errors name the timeout phase that expired,
+// and so reads fail if the body goes idle for longer than the body read timeout.
+func (hr *Request) newTimeoutBody(body io.ReadCloser) io.ReadCloser {
+	if body == nil || (hr.BodyReadTimeout == time.Duration(0) && hr.Timeout == time.Duration(0)) {
+		return body
+	}
+	tb := &timeoutBody{request: hr, body: body, idleTimeout: hr.BodyReadTimeout}
+	if tb.idleTimeout != time.Duration(0) {
+		tb.timer = time.AfterFunc(tb.idleTimeout, tb.expire)
+	}
+	return tb
+}
+
+type timeoutBody struct {
+	sync.Mutex
+	request     *Request
+	body        io.ReadCloser
+	idleTimeout time.Duration
+	timer       *time.Timer
+	expired     bool
+}
+
+func (tb *timeoutBody) expire() {
+	tb.Lock()
+	tb.expired = true
+	tb.Unlock()
+	tb.body.Close()
+}
+
+func (tb *timeoutBody) Read(p []byte) (int, error) {
+	n, err := tb.body.Read(p)
+
+	tb.Lock()
+	expired := tb.expired
+	tb.Unlock()
+	if expired {
+		return n, &TimeoutError{Phase: TimeoutPhaseBodyRead, Duration: tb.idleTimeout, Err: io.ErrUnexpectedEOF}
+	}
+	if tb.timer != nil {
+		tb.timer.Reset(tb.idleTimeout)
+	}
+	if err != nil && err != io.EOF {
+		return n, tb.request.timeoutError(err, TimeoutPhaseOverall)
+	}
+	return n, err
+}
+
+func (tb *timeoutBody) Close() error {
+	if tb.timer != nil {
+		tb.timer.Stop()
+	}
+	return tb.body.Close()
+}
@@ -0,0 +1,139 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func hangingEndpoint(beforeHang func(w http.ResponseWriter)) (string, func()) {
+	release := make(chan struct{})
+	ts := getMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if beforeHang != nil {
+			beforeHang(w)
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	return ts.URL, func() {
+		close(release)
+		ts.Close()
+	}
+}
+
+func assertTimeoutPhase(assert *assert.Assertions, phase TimeoutPhase, err error) {
+	assert.NotNil(err)
+	var timeoutErr *TimeoutError
+	assert.True(errors.As(err, &timeoutErr), err.Error())
+	assert.Equal(phase, timeoutErr.Phase)
+	assert.Equal(ErrorClassTimeout, ClassifyError(err))
+}
+
+func TestTimeoutOverall(t *testing.T) {
+	assert := assert.New(t)
+
+	url, done := hangingEndpoint(nil)
+	defer done()
+
+	var status statusObject
+	_, err := New().WithURL(url).WithTimeout(20 * time.Millisecond).JSONWithMeta(&status)
+	assertTimeoutPhase(assert, TimeoutPhaseOverall, err)
+}
+
+func TestTimeoutResponseHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	url, done := hangingEndpoint(nil)
+	defer done()
+
+	_, err := New().WithURL(url).WithTimeout(time.Second).WithResponseHeaderTimeout(20 * time.Millisecond).String()
+	assertTimeoutPhase(assert, TimeoutPhaseResponseHeader, err)
+}
+
+func TestTimeoutConnect(t *testing.T) {
+	assert := assert.New(t)
+
+	err := New().WithURL("http://unreachable.internal/").
+		WithConnectTimeout(20 * time.Millisecond).
+		WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}).
+		Execute()
+	assertTimeoutPhase(assert, TimeoutPhaseConnect, err)
+}
+
+func TestTimeoutOverallWhileConnecting(t *testing.T) {
+	assert := assert.New(t)
+
+	err := New().WithURL("http://unreachable.internal/").
+		WithTimeout(20 * time.Millisecond).
+		WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}).
+		Execute()
+	assertTimeoutPhase(assert, TimeoutPhaseConnect, err)
+}
+
+func TestTimeoutCallerDeadline(t *testing.T) {
+	assert := assert.New(t)
+
+	url, done := hangingEndpoint(nil)
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var errs []error
+	err := New().WithURL(url).WithContext(ctx).WithResponseHeaderTimeout(time.Second).
+		OnError(func(_ *Meta, err error) {
+			errs = append(errs, err)
+		}).
+		Execute()
+	assert.NotNil(err)
+	assert.Len(errs, 1)
+	var timeoutErr *TimeoutError
+	assert.False(errors.As(errs[0], &timeoutErr))
+}
+
+func TestTimeoutTLSHandshake(t *testing.T) {
+	assert := assert.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	err = New().WithURL("https://" + listener.Addr().String()).WithTLSHandshakeTimeout(20 * time.Millisecond).Execute()
+	assertTimeoutPhase(assert, TimeoutPhaseTLSHandshake, err)
+}
+
+func TestTimeoutBodyRead(t *testing.T) {
+	assert := assert.New(t)
+
+	url, done := hangingEndpoint(func(w http.ResponseWriter) {
+		w.Write([]byte(`{"status":`))
+		w.(http.Flusher).Flush()
+	})
+	defer done()
+
+	var status statusObject
+	err := New().WithURL(url).WithTimeout(time.Second).WithBodyReadTimeout(20 * time.Millisecond).JSON(&status)
+	assertTimeoutPhase(assert, TimeoutPhaseBodyRead, err)
+}
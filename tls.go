@@ -0,0 +1,181 @@
+package request
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+
+	exception "github.com/blendlabs/go-exception"
+)
+
+// WithClientTLSCertProvider sets a provider that supplies the client certificate for each tls handshake.
+func (hr *Request) WithClientTLSCertProvider(provider *CertificateProvider) *Request {
+	hr.certificateProvider = provider
+	return hr
+}
+
+// WithClientTLSCertReload reloads the client certificate and key set with `WithClientTLSCert` and
+// `WithClientTLSKey` when they change on disk, so transports that are reused pick up rotated credentials.
+func (hr *Request) WithClientTLSCertReload() *Request {
+	hr.TLSClientCertReload = true
+	return hr
+}
+
+// WithRootCAs sets the certificate authorities used to verify the server.
+func (hr *Request) WithRootCAs(pool *x509.CertPool) *Request {
+	hr.TLSRootCAs = pool
+	return hr
+}
+
+// WithRootCAsPEM sets the certificate authorities used to verify the server from a pem encoded bundle.
+func (hr *Request) WithRootCAsPEM(bundle []byte) *Request {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		hr.err = exception.New("no certificates found in root ca bundle")
+		return hr
+	}
+	hr.TLSRootCAs = pool
+	return hr
+}
+
+// WithClientTLSCertificate sets an in memory client certificate on the transport for the request.
+// It takes precedence over `WithClientTLSCert` and `WithClientTLSKey`.
+func (hr *Request) WithClientTLSCertificate(cert tls.Certificate) *Request {
+	hr.TLSClientCert = &cert
+	return hr
+}
+
+// WithClientTLSCertPEM sets a client certificate on the transport for the request from pem encoded bytes.
+func (hr *Request) WithClientTLSCertPEM(certPEM, keyPEM []byte) *Request {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		hr.err = exception.Wrap(err)
+		return hr
+	}
+	return hr.WithClientTLSCertificate(cert)
+}
+
+// WithPinnedPublicKeys requires the verified server certificate chain to include a public key with one of the given hashes.
+// If tls verification is skipped the server's leaf certificate must match.
+// Hashes are the base64 encoded sha256 of the certificate's subject public key info, optionally
+// prefixed with `sha256/` as in `curl --pinnedpubkey`.
+func (hr *Request) WithPinnedPublicKeys(hashes ...string) *Request {
+	for _, hash := range hashes {
+		pin, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(hash, "sha256/"))
+		if err != nil || len(pin) != sha256.Size {
+			hr.err = exception.Newf("invalid public key pin: %q", hash)
+			return hr
+		}
+		hr.TLSPinnedPublicKeys = append(hr.TLSPinnedPublicKeys, pin)
+	}
+	return hr
+}
+
+// WithTLSMinVersion sets the minimum tls version, i.e. `tls.VersionTLS12`.
+func (hr *Request) WithTLSMinVersion(version uint16) *Request {
+	hr.TLSMinVersion = version
+	return hr
+}
+
+// WithTLSServerName overrides the server name used for sni and certificate verification.
+func (hr *Request) WithTLSServerName(serverName string) *Request {
+	hr.TLSServerName = serverName
+	return hr
+}
+
+// PublicKeyPin returns the pin for a certificate as used by `WithPinnedPublicKeys`.
+func PublicKeyPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func (hr *Request) requiresCustomTLS() bool {
+	return (!isEmpty(hr.TLSClientCertPath) && !isEmpty(hr.TLSClientKeyPath)) ||
+		hr.TLSClientCert != nil ||
+		hr.certificateProvider != nil ||
+		hr.TLSSkipVerify ||
+		hr.TLSRootCAs != nil ||
+		len(hr.TLSPinnedPublicKeys) > 0 ||
+		hr.TLSMinVersion != 0 ||
+		!isEmpty(hr.TLSServerName)
+}
+
+func (hr *Request) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: hr.TLSSkipVerify,
+		RootCAs:            hr.TLSRootCAs,
+		MinVersion:         hr.TLSMinVersion,
+		ServerName:         hr.TLSServerName,
+	}
+
+	if hr.TLSClientCert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*hr.TLSClientCert}
+	} else if hr.certificateProvider != nil {
+		tlsConfig.GetClientCertificate = hr.certificateProvider.GetClientCertificate
+	} else if hr.TLSClientCertReload && !isEmpty(hr.TLSClientCertPath) && !isEmpty(hr.TLSClientKeyPath) {
+		provider, err := NewCertificateProvider(hr.TLSClientCertPath, hr.TLSClientKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = provider.WithLogger(hr.logger).GetClientCertificate
+	} else if !isEmpty(hr.TLSClientCertPath) && !isEmpty(hr.TLSClientKeyPath) {
+		cert, err := tls.LoadX509KeyPair(hr.TLSClientCertPath, hr.TLSClientKeyPath)
+		if err != nil {
+			return nil, exception.Wrap(err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(hr.TLSPinnedPublicKeys) > 0 {
+		tlsConfig.VerifyPeerCertificate = verifyPinnedPublicKeys(hr.TLSPinnedPublicKeys, hr.TLSSkipVerify)
+	}
+	return tlsConfig, nil
+}
+
+// PublicKeyPinError is returned when no certificate of the server matches a pinned public key.
+type PublicKeyPinError struct{}
+
+// Error implements error.
+func (pe *PublicKeyPinError) Error() string {
+	return "request: no certificate in the server chain matches a pinned public key"
+}
+
+func (pe *PublicKeyPinError) isTyped() {}
+
+// verifyPinnedPublicKeys matches the pins against the verified chains, so certificates the server appends
+// to its chain without them being part of a trusted path are never considered.
+// When verification is skipped there are no verified chains and only the leaf certificate is checked.
+func verifyPinnedPublicKeys(pins [][]byte, skipVerify bool) func([][]byte, [][]*x509.Certificate) error {
+	matches := func(cert *x509.Certificate) bool {
+		hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		for _, pin := range pins {
+			if bytes.Equal(hash[:], pin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if skipVerify {
+			if len(rawCerts) > 0 {
+				if leaf, err := x509.ParseCertificate(rawCerts[0]); err == nil && matches(leaf) {
+					return nil
+				}
+			}
+			return &PublicKeyPinError{}
+		}
+
+		for _, chain := range verifiedChains {
+			for _, cert := range chain {
+				if matches(cert) {
+					return nil
+				}
+			}
+		}
+		return &PublicKeyPinError{}
+	}
+}
@@ -0,0 +1,150 @@
+package request
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	assert "github.com/blendlabs/go-assert"
+)
+
+func selfSignedPEM(commonName string) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+func TestWithRootCAs(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getTLSMockServer(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, okMeta(), statusOkObject())
+	})
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	var status statusObject
+	assert.Nil(New().WithURL(ts.URL).WithRootCAs(pool).JSON(&status))
+	assert.Equal("ok!", status.Status)
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	assert.Nil(New().WithURL(ts.URL).WithRootCAsPEM(caPEM).WithTLSMinVersion(tls.VersionTLS12).JSON(&status))
+
+	_, err := New().WithURL(ts.URL).WithRootCAsPEM([]byte("garbage")).Request()
+	assert.NotNil(err)
+}
+
+func TestWithPinnedPublicKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getTLSMockServer(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, okMeta(), statusOkObject())
+	})
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	var status statusObject
+	err := New().WithURL(ts.URL).WithRootCAs(pool).WithPinnedPublicKeys(PublicKeyPin(ts.Certificate())).JSON(&status)
+	assert.Nil(err)
+
+	certPEM, _, err := selfSignedPEM("other")
+	assert.Nil(err)
+	block, _ := pem.Decode(certPEM)
+	other, err := x509.ParseCertificate(block.Bytes)
+	assert.Nil(err)
+
+	err = New().WithURL(ts.URL).WithRootCAs(pool).WithPinnedPublicKeys(PublicKeyPin(other)).JSON(&status)
+	assert.NotNil(err)
+
+	_, err = New().WithURL(ts.URL).WithPinnedPublicKeys("sha256/not-a-pin").Request()
+	assert.NotNil(err)
+}
+
+func TestWithPinnedPublicKeysAppendedCertificate(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := getTLSMockServer(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, okMeta(), statusOkObject())
+	})
+	defer ts.Close()
+
+	// the server appends a certificate that is not part of the verified chain.
+	certPEM, _, err := selfSignedPEM("appended")
+	assert.Nil(err)
+	block, _ := pem.Decode(certPEM)
+	appended, err := x509.ParseCertificate(block.Bytes)
+	assert.Nil(err)
+	ts.TLS.Certificates[0].Certificate = append(ts.TLS.Certificates[0].Certificate, appended.Raw)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	var status statusObject
+	var class ErrorClass
+	err = New().WithURL(ts.URL).WithRootCAs(pool).WithPinnedPublicKeys(PublicKeyPin(appended)).OnError(func(req *Meta, err error) {
+		class = ClassifyError(err)
+	}).JSON(&status)
+	assert.NotNil(err)
+	assert.Equal(ErrorClassTLS, class)
+
+	err = New().WithURL(ts.URL).WithVerifyTLS(false).WithPinnedPublicKeys(PublicKeyPin(appended)).JSON(&status)
+	assert.NotNil(err)
+
+	err = New().WithURL(ts.URL).WithVerifyTLS(false).WithPinnedPublicKeys(PublicKeyPin(ts.Certificate())).JSON(&status)
+	assert.Nil(err)
+}
+
+func TestWithClientTLSCertPEM(t *testing.T) {
+	assert := assert.New(t)
+
+	var commonName string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			commonName = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		writeJSON(w, okMeta(), statusOkObject())
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	certPEM, keyPEM, err := selfSignedPEM("borrower-service")
+	assert.Nil(err)
+
+	var status statusObject
+	err = New().WithURL(ts.URL).WithVerifyTLS(false).WithClientTLSCertPEM(certPEM, keyPEM).JSON(&status)
+	assert.Nil(err)
+	assert.Equal("borrower-service", commonName)
+}